@@ -17,7 +17,7 @@ var template string
 var generator = gotemplate.MustParse("framework/app/app.gotext", template)
 
 func Generate(state *State) ([]byte, error) {
-	return generator.Generate(state)
+	return generator.GenerateFormatted(state)
 }
 
 func New(injector *di.Injector, module *gomod.Module, flag *framework.Flag) *Generator {
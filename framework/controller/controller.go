@@ -20,7 +20,7 @@ var generator = gotemplate.MustParse("framework/controller/controller.gotext", t
 
 // Generate the controller template from state
 func Generate(state *State) ([]byte, error) {
-	return generator.Generate(state)
+	return generator.GenerateFormatted(state)
 }
 
 // New controller generator
@@ -1,8 +1,74 @@
 package framework
 
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
 // Flag is used by many of the framework generators
 type Flag struct {
 	Embed  bool
 	Minify bool
 	Hot    bool
 }
+
+// ErrHotEmbed is returned when hot reloading is enabled along with embedding
+var ErrHotEmbed = errors.New("framework: hot reloading can't be used with embedded assets, pass either --hot=false or --embed=false")
+
+// Validate checks for flag combinations that don't make sense together
+func (f *Flag) Validate() error {
+	if f.Hot && f.Embed {
+		return ErrHotEmbed
+	}
+	return nil
+}
+
+// Merge returns a new flag where the zero-valued fields of f are filled in by
+// the fields of other
+func (f *Flag) Merge(other *Flag) *Flag {
+	merged := *f
+	if other == nil {
+		return &merged
+	}
+	mv := reflect.ValueOf(&merged).Elem()
+	ov := reflect.ValueOf(other).Elem()
+	for i := 0; i < mv.NumField(); i++ {
+		if mv.Field(i).IsZero() {
+			mv.Field(i).Set(ov.Field(i))
+		}
+	}
+	return &merged
+}
+
+// envPrefix is the prefix for flags set with environment variables
+const envPrefix = "BUD_"
+
+// FlagFromEnv loads the flag from BUD_* environment variables. For example,
+// BUD_EMBED=true sets the Embed field. Unset variables are left as zero values.
+func FlagFromEnv() (*Flag, error) {
+	flag := new(Flag)
+	fv := reflect.ValueOf(flag).Elem()
+	ft := fv.Type()
+	for i := 0; i < ft.NumField(); i++ {
+		key := envPrefix + strings.ToUpper(ft.Field(i).Name)
+		value, ok := os.LookupEnv(key)
+		if !ok || value == "" {
+			continue
+		}
+		switch fv.Field(i).Kind() {
+		case reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("framework: unable to parse %s=%q as a boolean. %w", key, value, err)
+			}
+			fv.Field(i).SetBool(b)
+		default:
+			return nil, fmt.Errorf("framework: unsupported flag type %s for %s", fv.Field(i).Kind(), key)
+		}
+	}
+	return flag, nil
+}
@@ -0,0 +1,45 @@
+package framework_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/livebud/bud/framework"
+	"github.com/livebud/bud/internal/is"
+)
+
+func TestValidate(t *testing.T) {
+	is := is.New(t)
+	is.NoErr((&framework.Flag{}).Validate())
+	is.NoErr((&framework.Flag{Hot: true, Minify: true}).Validate())
+	is.NoErr((&framework.Flag{Embed: true, Minify: true}).Validate())
+	err := (&framework.Flag{Embed: true, Hot: true}).Validate()
+	is.True(errors.Is(err, framework.ErrHotEmbed))
+}
+
+func TestMerge(t *testing.T) {
+	is := is.New(t)
+	cli := &framework.Flag{Minify: true}
+	env := &framework.Flag{Embed: true, Minify: false}
+	merged := cli.Merge(env)
+	is.Equal(merged, &framework.Flag{Embed: true, Minify: true})
+	// Merge doesn't modify either flag
+	is.Equal(cli, &framework.Flag{Minify: true})
+	is.Equal(env, &framework.Flag{Embed: true})
+	is.Equal(cli.Merge(nil), &framework.Flag{Minify: true})
+}
+
+func TestFlagFromEnv(t *testing.T) {
+	is := is.New(t)
+	t.Setenv("BUD_EMBED", "true")
+	t.Setenv("BUD_MINIFY", "1")
+	flag, err := framework.FlagFromEnv()
+	is.NoErr(err)
+	is.Equal(flag, &framework.Flag{Embed: true, Minify: true})
+	t.Setenv("BUD_HOT", "maybe")
+	flag, err = framework.FlagFromEnv()
+	is.True(err != nil)
+	is.Equal(flag, nil)
+	is.True(strings.Contains(err.Error(), `BUD_HOT="maybe"`))
+}
@@ -17,7 +17,7 @@ var generator = gotemplate.MustParse("framework/public/public.gotext", template)
 
 // Generate the public file
 func Generate(state *State) ([]byte, error) {
-	return generator.Generate(state)
+	return generator.GenerateFormatted(state)
 }
 
 // New public generator
@@ -17,7 +17,7 @@ var generator = gotemplate.MustParse("framework/view/view.gotext", template)
 
 // Generate the view from state
 func Generate(state *State) ([]byte, error) {
-	return generator.Generate(state)
+	return generator.GenerateFormatted(state)
 }
 
 func New(module *gomod.Module, transform *transformrt.Map, flag *framework.Flag) *Generator {
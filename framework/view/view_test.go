@@ -103,7 +103,7 @@ func TestHelloEmbed(t *testing.T) {
 	td.NodeModules["livebud"] = "*"
 	is.NoErr(td.Write(ctx))
 	cli := testcli.New(dir)
-	app, err := cli.Start(ctx, "run", "--embed")
+	app, err := cli.Start(ctx, "run", "--embed", "--hot=false")
 	is.NoErr(err)
 	defer app.Close()
 	hot, err := app.Hot("/bud/hot/view/index.svelte")
@@ -175,7 +175,7 @@ func TestChunks(t *testing.T) {
 	td.NodeModules["livebud"] = "*"
 	is.NoErr(td.Write(ctx))
 	cli := testcli.New(dir)
-	app, err := cli.Start(ctx, "run", "--embed")
+	app, err := cli.Start(ctx, "run", "--embed", "--hot=false")
 	is.NoErr(err)
 	defer app.Close()
 	// Ensure we have an index
@@ -16,7 +16,7 @@ var generator = gotemplate.MustParse("framework/web/web.gotext", template)
 
 // Generate the web server from state
 func Generate(state *State) ([]byte, error) {
-	return generator.Generate(state)
+	return generator.GenerateFormatted(state)
 }
 
 func New(module *gomod.Module, parser *parser.Parser) *Generator {
@@ -33,7 +33,7 @@ func (g *Generator) GenerateFile(fsys budfs.FS, file *budfs.File) error {
 	if err != nil {
 		return err
 	}
-	code, err := generator.Generate(state)
+	code, err := generator.GenerateFormatted(state)
 	if err != nil {
 		return err
 	}
@@ -119,6 +119,9 @@ func Log(stderr io.Writer, logFilter string) (log.Interface, error) {
 }
 
 func FileSystem(ctx context.Context, log log.Interface, module *gomod.Module, flag *framework.Flag, in *Input) (*budfs.FileSystem, error) {
+	if err := flag.Validate(); err != nil {
+		return nil, err
+	}
 	bfs := budfs.New(module, log)
 	parser := parser.New(bfs, module)
 	injector := di.New(bfs, log, module, parser)
@@ -46,6 +46,10 @@ func (c *Command) Run(ctx context.Context) error {
 		return err
 	}
 	defer bfs.Close()
+	// Run the generators before writing anything to disk
+	if err := bfs.Check(ctx); err != nil {
+		return err
+	}
 	if err := bfs.Sync(module, "bud/internal"); err != nil {
 		return err
 	}
@@ -1,20 +1,29 @@
 package dsync
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"io/fs"
+	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/livebud/bud/internal/dsync/set"
 	"github.com/livebud/bud/package/vfs"
+	"github.com/livebud/bud/package/virtual"
 )
 
 type skipFunc = func(name string, isDir bool) bool
 
 type option struct {
-	Skip skipFunc
-	rel  func(spath string) (string, error)
+	Skip    skipFunc
+	rel     func(spath string) (string, error)
+	ctx     context.Context
+	workers int
 }
 
 type Option func(o *option)
@@ -30,6 +39,21 @@ func WithSkip(skips ...skipFunc) Option {
 	}
 }
 
+// WithWorkers sets the number of files that are written in parallel. Defaults
+// to the lesser of 4 and the number of CPUs.
+func WithWorkers(n int) Option {
+	return func(o *option) {
+		o.workers = n
+	}
+}
+
+// withContext aborts the sync when the context is cancelled
+func withContext(ctx context.Context) Option {
+	return func(o *option) {
+		o.ctx = ctx
+	}
+}
+
 func composeSkips(skips []skipFunc) skipFunc {
 	return func(name string, isDir bool) bool {
 		for _, skip := range skips {
@@ -55,8 +79,10 @@ func Rel(sdir, tdir string) func(path string) (string, error) {
 // in the target filesystem
 func Dir(sfs fs.FS, sdir string, tfs vfs.ReadWritable, tdir string, options ...Option) error {
 	opt := &option{
-		Skip: func(name string, isDir bool) bool { return false },
-		rel:  Rel(sdir, tdir),
+		Skip:    func(name string, isDir bool) bool { return false },
+		rel:     Rel(sdir, tdir),
+		ctx:     context.Background(),
+		workers: defaultWorkers(),
 	}
 	for _, option := range options {
 		option(opt)
@@ -65,13 +91,53 @@ func Dir(sfs fs.FS, sdir string, tfs vfs.ReadWritable, tdir string, options ...O
 	if err != nil {
 		return err
 	}
-	err = apply(sfs, tfs, ops)
+	err = apply(opt, sfs, tfs, ops)
 	return err
 }
 
 // To syncs the "to" directory from the source to target filesystem
-func To(sfs fs.FS, tfs vfs.ReadWritable, to string) error {
-	return Dir(sfs, to, tfs, to)
+func To(sfs fs.FS, tfs vfs.ReadWritable, to string, options ...Option) error {
+	return ToContext(context.Background(), sfs, tfs, to, options...)
+}
+
+// ToContext syncs the "to" directory from the source to target filesystem,
+// returning early with the context's error if the context is cancelled
+func ToContext(ctx context.Context, sfs fs.FS, tfs vfs.ReadWritable, to string, options ...Option) error {
+	return Dir(sfs, to, tfs, to, append([]Option{withContext(ctx)}, options...)...)
+}
+
+// FromDir copies the regular files in srcDir into dst. This is the reverse of
+// To, useful for loading a directory into a virtual filesystem.
+func FromDir(srcDir string, dst virtual.FS) error {
+	sfs := os.DirFS(srcDir)
+	return fs.WalkDir(sfs, ".", func(path string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if path == "." {
+			return nil
+		}
+		info, err := de.Info()
+		if err != nil {
+			return err
+		}
+		if de.IsDir() {
+			return dst.MkdirAll(path, info.Mode().Perm())
+		} else if !info.Mode().IsRegular() {
+			return nil
+		}
+		data, err := fs.ReadFile(sfs, path)
+		if err != nil {
+			return err
+		}
+		return dst.WriteFile(path, data, info.Mode().Perm())
+	})
+}
+
+func defaultWorkers() int {
+	if n := runtime.NumCPU(); n < 4 {
+		return n
+	}
+	return 4
 }
 
 type OpType uint8
@@ -139,6 +205,9 @@ func diff(opt *option, sfs fs.FS, sdir string, tfs vfs.ReadWritable, tdir string
 
 func createOps(opt *option, sfs fs.FS, dir string, des []fs.DirEntry) (ops []Op, err error) {
 	for _, de := range des {
+		if err := opt.ctx.Err(); err != nil {
+			return nil, err
+		}
 		if de.Name() == "." {
 			continue
 		}
@@ -177,6 +246,9 @@ func createOps(opt *option, sfs fs.FS, dir string, des []fs.DirEntry) (ops []Op,
 
 func deleteOps(opt *option, dir string, des []fs.DirEntry) (ops []Op, err error) {
 	for _, de := range des {
+		if err := opt.ctx.Err(); err != nil {
+			return nil, err
+		}
 		// Don't allow the directory itself to be deleted
 		if de.Name() == "." {
 			continue
@@ -197,6 +269,9 @@ func deleteOps(opt *option, dir string, des []fs.DirEntry) (ops []Op, err error)
 
 func updateOps(opt *option, sfs fs.FS, sdir string, tfs vfs.ReadWritable, tdir string, des []fs.DirEntry) (ops []Op, err error) {
 	for _, de := range des {
+		if err := opt.ctx.Err(); err != nil {
+			return nil, err
+		}
 		if de.Name() == "." {
 			continue
 		}
@@ -235,6 +310,12 @@ func updateOps(opt *option, sfs fs.FS, sdir string, tfs vfs.ReadWritable, tdir s
 			}
 			return nil, err
 		}
+		// Skip if only the modtime changed and the contents are the same
+		if same, err := sameContents(tfs, tpath, data); err != nil {
+			return nil, err
+		} else if same {
+			continue
+		}
 		rel, err := opt.rel(spath)
 		if err != nil {
 			return nil, err
@@ -244,30 +325,167 @@ func updateOps(opt *option, sfs fs.FS, sdir string, tfs vfs.ReadWritable, tdir s
 	return ops, nil
 }
 
-func apply(sfs fs.FS, tfs vfs.ReadWritable, ops []Op) error {
+func apply(opt *option, sfs fs.FS, tfs vfs.ReadWritable, ops []Op) error {
+	// Only the OS filesystem is safe to write to concurrently
+	if _, ok := tfs.(vfs.OS); !ok && opt.workers > 1 {
+		tfs = &lockedFS{fsys: tfs}
+	}
+	// Writes are batched up and run in parallel. Deletes act as a barrier to
+	// keep the order of operations intact.
+	writes := make([]Op, 0, len(ops))
 	for _, op := range ops {
-		switch op.Type {
-		case CreateType:
-			dir := filepath.Dir(op.Path)
-			if err := tfs.MkdirAll(dir, 0755); err != nil {
-				return err
-			}
-			if err := tfs.WriteFile(op.Path, op.Data, 0644); err != nil {
+		if op.Type != DeleteType {
+			writes = append(writes, op)
+			continue
+		}
+		if err := writeAll(opt, tfs, writes); err != nil {
+			return err
+		}
+		writes = writes[:0]
+		if err := opt.ctx.Err(); err != nil {
+			return err
+		}
+		if err := tfs.RemoveAll(op.Path); err != nil {
+			return err
+		}
+	}
+	return writeAll(opt, tfs, writes)
+}
+
+func writeAll(opt *option, tfs vfs.ReadWritable, ops []Op) error {
+	if opt.workers <= 1 || len(ops) <= 1 {
+		for _, op := range ops {
+			if err := opt.ctx.Err(); err != nil {
 				return err
 			}
-		case UpdateType:
-			if err := tfs.WriteFile(op.Path, op.Data, 0644); err != nil {
+			if err := write(tfs, op); err != nil {
 				return err
 			}
-		case DeleteType:
-			if err := tfs.RemoveAll(op.Path); err != nil {
-				return err
+		}
+		return nil
+	}
+	return writeParallel(opt, tfs, ops)
+}
+
+// writeParallel fans the writes out to a pool of workers
+func writeParallel(opt *option, tfs vfs.ReadWritable, ops []Op) error {
+	queue := make(chan Op)
+	var mu sync.Mutex
+	var errs MultiError
+	var wg sync.WaitGroup
+	for i := 0; i < opt.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for op := range queue {
+				if err := write(tfs, op); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
 			}
+		}()
+	}
+	for _, op := range ops {
+		if err := opt.ctx.Err(); err != nil {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+			break
+		}
+		queue <- op
+	}
+	close(queue)
+	wg.Wait()
+	if len(errs) == 0 {
+		return nil
+	} else if len(errs) == 1 {
+		return errs[0]
+	}
+	return errs
+}
+
+func write(tfs vfs.ReadWritable, op Op) error {
+	switch op.Type {
+	case CreateType:
+		dir := filepath.Dir(op.Path)
+		if err := tfs.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := tfs.WriteFile(op.Path, op.Data, 0644); err != nil {
+			return err
+		}
+	case UpdateType:
+		if err := tfs.WriteFile(op.Path, op.Data, 0644); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// lockedFS serializes access to filesystems that aren't safe for concurrent use
+type lockedFS struct {
+	mu   sync.Mutex
+	fsys vfs.ReadWritable
+}
+
+func (l *lockedFS) Open(name string) (fs.File, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fsys.Open(name)
+}
+
+func (l *lockedFS) MkdirAll(path string, perm fs.FileMode) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fsys.MkdirAll(path, perm)
+}
+
+func (l *lockedFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fsys.WriteFile(name, data, perm)
+}
+
+func (l *lockedFS) RemoveAll(path string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fsys.RemoveAll(path)
+}
+
+// MultiError aggregates the errors from parallel writes
+type MultiError []error
+
+func (errs MultiError) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return "dsync: " + strings.Join(msgs, ". ")
+}
+
+// Is returns true if any of the errors match the target
+func (errs MultiError) Is(target error) bool {
+	for _, err := range errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameContents returns true if the target file has the same contents as data
+func sameContents(fsys fs.FS, path string, data []byte) (bool, error) {
+	target, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return bytes.Equal(target, data), nil
+}
+
 // Stamp the path, returning "" if the file doesn't exist.
 // Uses the modtime and size to determine if a file has changed.
 func stamp(fsys fs.FS, path string) (stamp string, err error) {
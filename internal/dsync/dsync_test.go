@@ -1,9 +1,13 @@
 package dsync_test
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io/fs"
+	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
@@ -11,6 +15,7 @@ import (
 	"github.com/livebud/bud/internal/is"
 	"github.com/livebud/bud/package/budfs/treefs"
 	"github.com/livebud/bud/package/vfs"
+	"github.com/livebud/bud/package/virtual"
 )
 
 func TestFileSync(t *testing.T) {
@@ -325,3 +330,89 @@ func TestRel(t *testing.T) {
 	is.NoErr(err)
 	is.Equal(rel, "app/a/a.go")
 }
+
+func TestToContextCancelled(t *testing.T) {
+	is := is.New(t)
+	sourceFS := vfs.Memory{
+		"a.txt": &vfs.File{Data: []byte("a")},
+		"b.txt": &vfs.File{Data: []byte("b")},
+	}
+	targetFS := vfs.Memory{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := dsync.ToContext(ctx, sourceFS, targetFS, ".")
+	is.True(errors.Is(err, context.Canceled))
+	is.Equal(len(targetFS), 0)
+	// Syncs with a live context
+	err = dsync.ToContext(context.Background(), sourceFS, targetFS, ".")
+	is.NoErr(err)
+	is.Equal(len(targetFS), 2)
+}
+
+func TestWithWorkers(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	sourceFS := vfs.Memory{}
+	for i := 0; i < 50; i++ {
+		sourceFS[fmt.Sprintf("bud/%d/%d.txt", i%5, i)] = &vfs.File{Data: []byte(strconv.Itoa(i))}
+	}
+	targetFS := vfs.OS(dir)
+	err := dsync.To(sourceFS, targetFS, "bud", dsync.WithWorkers(8))
+	is.NoErr(err)
+	for i := 0; i < 50; i++ {
+		data, err := fs.ReadFile(targetFS, fmt.Sprintf("bud/%d/%d.txt", i%5, i))
+		is.NoErr(err)
+		is.Equal(string(data), strconv.Itoa(i))
+	}
+	// In-memory targets are written safely too
+	memoryFS := vfs.Memory{}
+	err = dsync.To(sourceFS, memoryFS, "bud", dsync.WithWorkers(8))
+	is.NoErr(err)
+	data, err := fs.ReadFile(memoryFS, "bud/4/49.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "49")
+}
+
+// failFS fails to write files
+type failFS struct {
+	vfs.Memory
+}
+
+func (f failFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return fmt.Errorf("unable to write %q. %w", name, fs.ErrPermission)
+}
+
+func TestWithWorkersErrors(t *testing.T) {
+	is := is.New(t)
+	sourceFS := vfs.Memory{
+		"a.txt": &vfs.File{Data: []byte("a")},
+		"b.txt": &vfs.File{Data: []byte("b")},
+	}
+	err := dsync.To(sourceFS, failFS{vfs.Memory{}}, ".", dsync.WithWorkers(2))
+	is.True(err != nil)
+	is.True(errors.Is(err, fs.ErrPermission))
+	var multi dsync.MultiError
+	is.True(errors.As(err, &multi))
+	is.Equal(len(multi), 2)
+}
+
+func TestFromDir(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	is.NoErr(os.MkdirAll(filepath.Join(dir, "view", "empty"), 0755))
+	is.NoErr(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module app.com"), 0644))
+	is.NoErr(os.WriteFile(filepath.Join(dir, "view", "index.svelte"), []byte("<h1>index</h1>"), 0644))
+	fsys := virtual.Tree{}
+	is.NoErr(dsync.FromDir(dir, fsys))
+	data, err := fs.ReadFile(fsys, "go.mod")
+	is.NoErr(err)
+	is.Equal(string(data), "module app.com")
+	data, err = fs.ReadFile(fsys, "view/index.svelte")
+	is.NoErr(err)
+	is.Equal(string(data), "<h1>index</h1>")
+	stat, err := fs.Stat(fsys, "view/empty")
+	is.NoErr(err)
+	is.True(stat.IsDir())
+	// Missing directories fail
+	is.True(dsync.FromDir(filepath.Join(dir, "missing"), fsys) != nil)
+}
@@ -1,8 +1,132 @@
 package glob
 
-import "github.com/gobwas/glob"
+import (
+	"strings"
 
-// Alias for glob.Glob
-var Compile = glob.Compile
+	"github.com/gobwas/glob"
+)
 
-type Matcher = glob.Glob
+// Matcher matches paths against a compiled pattern
+type Matcher interface {
+	// Match returns true if the path matches the pattern
+	Match(path string) bool
+	// CanSkipDir returns true if nothing within dir can match the pattern, so
+	// walks can skip descending into the directory
+	CanSkipDir(dir string) bool
+}
+
+// Flags change how patterns are compiled
+type Flags uint8
+
+const (
+	// FlagCaseInsensitive ignores case while matching
+	FlagCaseInsensitive Flags = 1 << iota
+)
+
+// Compile the pattern into a matcher
+func Compile(pattern string) (Matcher, error) {
+	return CompileWithFlags(pattern, 0)
+}
+
+// CompileWithFlags compiles the pattern into a matcher using flags. Braces are
+// expanded beforehand, so {*.go,*.ts} compiles into a matcher for *.go and a
+// matcher for *.ts that are combined.
+func CompileWithFlags(pattern string, flags Flags) (Matcher, error) {
+	if flags&FlagCaseInsensitive != 0 {
+		pattern = strings.ToLower(pattern)
+	}
+	patterns, err := Expand(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var matcher Matcher
+	if len(patterns) == 1 {
+		matcher, err = compile(patterns[0])
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		matchers := make(anyMatcher, len(patterns))
+		for i, pattern := range patterns {
+			matchers[i], err = compile(pattern)
+			if err != nil {
+				return nil, err
+			}
+		}
+		matcher = matchers
+	}
+	if flags&FlagCaseInsensitive != 0 {
+		return &foldMatcher{matcher}, nil
+	}
+	return matcher, nil
+}
+
+func compile(pattern string) (*patternMatcher, error) {
+	matcher, err := glob.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	// Find the part of the pattern before the first magic character
+	prefix := pattern
+	literal := true
+	if i := strings.IndexAny(pattern, "*?[{\\"); i >= 0 {
+		prefix = pattern[:i]
+		literal = false
+	}
+	return &patternMatcher{matcher, prefix, literal}, nil
+}
+
+// patternMatcher matches a single expanded pattern
+type patternMatcher struct {
+	glob.Glob
+	prefix  string // literal part of the pattern before any magic
+	literal bool   // true if the whole pattern is literal
+}
+
+// CanSkipDir checks the directory against the literal prefix. Wildcards may
+// match across path separators, so the prefix is the only part of the pattern
+// that reliably constrains which directories can contain matches.
+func (m *patternMatcher) CanSkipDir(dir string) bool {
+	if dir == "." || dir == "" {
+		return false
+	}
+	dir += "/"
+	if m.literal {
+		return !strings.HasPrefix(m.prefix, dir)
+	}
+	return !strings.HasPrefix(m.prefix, dir) && !strings.HasPrefix(dir, m.prefix)
+}
+
+// anyMatcher matches if any of the matchers match
+type anyMatcher []*patternMatcher
+
+func (matchers anyMatcher) Match(path string) bool {
+	for _, matcher := range matchers {
+		if matcher.Match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (matchers anyMatcher) CanSkipDir(dir string) bool {
+	for _, matcher := range matchers {
+		if !matcher.CanSkipDir(dir) {
+			return false
+		}
+	}
+	return true
+}
+
+// foldMatcher matches case-insensitively
+type foldMatcher struct {
+	Matcher
+}
+
+func (m *foldMatcher) Match(path string) bool {
+	return m.Matcher.Match(strings.ToLower(path))
+}
+
+func (m *foldMatcher) CanSkipDir(dir string) bool {
+	return m.Matcher.CanSkipDir(strings.ToLower(dir))
+}
@@ -3,7 +3,7 @@ package glob_test
 import (
 	"testing"
 
-	"github.com/gobwas/glob"
+	"github.com/livebud/bud/internal/glob"
 	"github.com/livebud/bud/internal/is"
 )
 
@@ -14,3 +14,76 @@ func TestMatch(t *testing.T) {
 	is.True(matcher.Match("controller/controller.go"))
 	is.True(matcher.Match("view/index.svelte"))
 }
+
+func TestCaseInsensitive(t *testing.T) {
+	is := is.New(t)
+	matcher, err := glob.CompileWithFlags("**/*.GO", glob.FlagCaseInsensitive)
+	is.NoErr(err)
+	is.True(matcher.Match("controller/main.go"))
+	is.True(matcher.Match("Controller/Main.Go"))
+	is.True(!matcher.Match("controller/main.ts"))
+	matcher, err = glob.Compile("**/*.GO")
+	is.NoErr(err)
+	is.True(!matcher.Match("controller/main.go"))
+	is.True(matcher.Match("controller/main.GO"))
+}
+
+func TestBraceExpansion(t *testing.T) {
+	is := is.New(t)
+	matcher, err := glob.Compile("{*.go,*.ts}")
+	is.NoErr(err)
+	is.True(matcher.Match("main.go"))
+	is.True(matcher.Match("main.ts"))
+	is.True(!matcher.Match("main.js"))
+	matcher, err = glob.Compile("{controller,view}/{*.go,**.svelte}")
+	is.NoErr(err)
+	is.True(matcher.Match("controller/main.go"))
+	is.True(matcher.Match("view/index.svelte"))
+	is.True(matcher.Match("view/users/index.svelte"))
+	is.True(!matcher.Match("public/main.go"))
+	matcher, err = glob.CompileWithFlags("{*.GO,*.TS}", glob.FlagCaseInsensitive)
+	is.NoErr(err)
+	is.True(matcher.Match("main.go"))
+	is.True(matcher.Match("Main.Ts"))
+}
+
+func TestCanSkipDir(t *testing.T) {
+	is := is.New(t)
+	matcher, err := glob.Compile("bud/generate/**/*.go")
+	is.NoErr(err)
+	is.True(!matcher.CanSkipDir("."))
+	is.True(!matcher.CanSkipDir("bud"))
+	is.True(!matcher.CanSkipDir("bud/generate"))
+	is.True(!matcher.CanSkipDir("bud/generate/web"))
+	is.True(matcher.CanSkipDir("bud/view"))
+	is.True(matcher.CanSkipDir("node_modules"))
+	is.True(matcher.CanSkipDir("bud/generated"))
+	// Partial literal segments
+	matcher, err = glob.Compile("view/us*/index.svelte")
+	is.NoErr(err)
+	is.True(!matcher.CanSkipDir("view"))
+	is.True(!matcher.CanSkipDir("view/users"))
+	is.True(matcher.CanSkipDir("view/posts"))
+	// Literal patterns
+	matcher, err = glob.Compile("view/index.svelte")
+	is.NoErr(err)
+	is.True(!matcher.CanSkipDir("view"))
+	is.True(matcher.CanSkipDir("view/index.svelte"))
+	is.True(matcher.CanSkipDir("controller"))
+	// Wildcards match across separators
+	matcher, err = glob.Compile("*.go")
+	is.NoErr(err)
+	is.True(!matcher.CanSkipDir("controller"))
+	// Braces
+	matcher, err = glob.Compile("{controller,view}/**")
+	is.NoErr(err)
+	is.True(!matcher.CanSkipDir("controller"))
+	is.True(!matcher.CanSkipDir("view"))
+	is.True(matcher.CanSkipDir("public"))
+	// Case insensitive
+	matcher, err = glob.CompileWithFlags("View/**", glob.FlagCaseInsensitive)
+	is.NoErr(err)
+	is.True(!matcher.CanSkipDir("view"))
+	is.True(!matcher.CanSkipDir("VIEW"))
+	is.True(matcher.CanSkipDir("public"))
+}
@@ -22,9 +22,8 @@ func Expand(str string) ([]string, error) {
 }
 
 func expand(node *ast.Node) (patterns []string, err error) {
-	prefix := ""
+	patterns = []string{""}
 	write := func(value string) {
-		prefix += value
 		for i := range patterns {
 			patterns[i] += value
 		}
@@ -68,21 +67,25 @@ func expand(node *ast.Node) (patterns []string, err error) {
 		case ast.KindSingle:
 			write("?")
 		case ast.KindAnyOf:
+			// Multiply the patterns so far by each of the alternatives
+			var alternatives []string
 			for _, child := range child.Children {
 				results, err := expand(child)
 				if err != nil {
 					return nil, err
 				}
-				for _, result := range results {
-					patterns = append(patterns, prefix+result)
+				alternatives = append(alternatives, results...)
+			}
+			product := make([]string, 0, len(patterns)*len(alternatives))
+			for _, pattern := range patterns {
+				for _, alternative := range alternatives {
+					product = append(product, pattern+alternative)
 				}
 			}
+			patterns = product
 		default:
 			return nil, fmt.Errorf("unknown node kind: %v", child.Kind)
 		}
 	}
-	if len(patterns) == 0 {
-		patterns = append(patterns, prefix)
-	}
 	return patterns, nil
 }
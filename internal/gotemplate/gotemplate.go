@@ -2,12 +2,19 @@ package gotemplate
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"go/format"
+	"go/scanner"
+	"regexp"
+	"strconv"
+	"strings"
 	"text/template"
 )
 
 type Template interface {
 	Generate(state interface{}) ([]byte, error)
+	GenerateFormatted(state interface{}) ([]byte, error)
 }
 
 // MustParse panics if unable to parse
@@ -50,3 +57,68 @@ func (t *gotemplate) Generate(state interface{}) ([]byte, error) {
 	}
 	return buf.Bytes(), nil
 }
+
+// GenerateFormatted generates Go code and formats it with gofmt. Unlike
+// Generate, an error is returned if the code can't be formatted.
+func (t *gotemplate) GenerateFormatted(state interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := t.tpl.Execute(buf, state); err != nil {
+		return nil, err
+	}
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("gotemplate: unable to format %q. %w", t.name, FormatWithContext(buf.Bytes(), err))
+	}
+	return code, nil
+}
+
+// contextLines is the number of lines to show before and after the failure
+const contextLines = 5
+
+// lineRe matches the line and column prefix of a format error
+var lineRe = regexp.MustCompile(`(?:^|\s)(\d+):(\d+):`)
+
+// FormatWithContext wraps a go/format error with the lines of src surrounding
+// the failure. The error is returned as-is if the line can't be determined.
+func FormatWithContext(src []byte, err error) error {
+	if err == nil {
+		return nil
+	}
+	line := errorLine(err)
+	lines := strings.Split(string(src), "\n")
+	if line < 1 || line > len(lines) {
+		return err
+	}
+	start := line - contextLines
+	if start < 1 {
+		start = 1
+	}
+	end := line + contextLines
+	if end > len(lines) {
+		end = len(lines)
+	}
+	width := len(strconv.Itoa(end))
+	snippet := new(strings.Builder)
+	for i := start; i <= end; i++ {
+		marker := " "
+		if i == line {
+			marker = ">"
+		}
+		fmt.Fprintf(snippet, "\n%s %*d | %s", marker, width, i, lines[i-1])
+	}
+	return fmt.Errorf("%w\n%s", err, snippet.String())
+}
+
+// errorLine returns the line number of the format error or 0
+func errorLine(err error) int {
+	var list scanner.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		return list[0].Pos.Line
+	}
+	match := lineRe.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0
+	}
+	line, _ := strconv.Atoi(match[1])
+	return line
+}
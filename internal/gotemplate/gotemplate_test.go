@@ -1,6 +1,9 @@
 package gotemplate_test
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/livebud/bud/internal/gotemplate"
@@ -35,3 +38,66 @@ func TestGenerateFreeText(t *testing.T) {
 	is.NoErr(err)
 	is.Equal(string(b), expect)
 }
+
+func TestGenerateFormatted(t *testing.T) {
+	is := is.New(t)
+	template := `package main
+
+	func main()  {
+		  println("{{ .name }}")
+}`
+	expect := `package main
+
+func main() {
+	println("jason")
+}
+`
+	generator := gotemplate.MustParse("test.gotext", template)
+	b, err := generator.GenerateFormatted(map[string]string{"name": "jason"})
+	is.NoErr(err)
+	is.Equal(string(b), expect)
+}
+
+func TestGenerateFormattedError(t *testing.T) {
+	is := is.New(t)
+	template := `package main
+
+func main() {
+	println({{ .name }})
+`
+	generator := gotemplate.MustParse("test.gotext", template)
+	b, err := generator.GenerateFormatted(map[string]string{"name": "jason"})
+	is.True(err != nil)
+	is.Equal(b, nil)
+	is.True(strings.Contains(err.Error(), `unable to format "test.gotext"`))
+	is.True(strings.Contains(err.Error(), "> 4 | \tprintln(jason)"))
+}
+
+func TestFormatWithContext(t *testing.T) {
+	is := is.New(t)
+	var src []string
+	for i := 1; i <= 20; i++ {
+		src = append(src, fmt.Sprintf("line%d", i))
+	}
+	err := gotemplate.FormatWithContext([]byte(strings.Join(src, "\n")), errors.New("12:3: expected declaration"))
+	is.Equal(err.Error(), `12:3: expected declaration
+
+   7 | line7
+   8 | line8
+   9 | line9
+  10 | line10
+  11 | line11
+> 12 | line12
+  13 | line13
+  14 | line14
+  15 | line15
+  16 | line16
+  17 | line17`)
+	// Near the start of the source
+	err = gotemplate.FormatWithContext([]byte(strings.Join(src, "\n")), errors.New("1:1: expected 'package'"))
+	is.True(strings.HasSuffix(err.Error(), "> 1 | line1\n  2 | line2\n  3 | line3\n  4 | line4\n  5 | line5\n  6 | line6"))
+	// Unknown lines are returned as-is
+	original := errors.New("unable to format")
+	is.Equal(gotemplate.FormatWithContext([]byte("package main"), original), original)
+	is.Equal(gotemplate.FormatWithContext(nil, nil), nil)
+}
@@ -16,6 +16,7 @@ import (
 func New() *Set {
 	return &Set{
 		names:    map[string]int{},
+		taken:    map[string]bool{},
 		paths:    map[string]string{},
 		reserved: map[string]string{},
 	}
@@ -24,10 +25,33 @@ func New() *Set {
 // Set of imports
 type Set struct {
 	names    map[string]int
+	taken    map[string]bool
 	paths    map[string]string
 	reserved map[string]string
 }
 
+// unique returns a name that hasn't been taken yet by adding a numeric suffix
+// when there's a conflict. Names that already end in a digit are suffixed with
+// an underscore, so rand1 becomes rand1_1 rather than rand11.
+func (s *Set) unique(name string) string {
+	separator := ""
+	if last, _ := utf8.DecodeLastRuneInString(name); unicode.IsDigit(last) {
+		separator = "_"
+	}
+	for {
+		ith := s.names[name]
+		s.names[name]++
+		uniqueName := name
+		if ith > 0 {
+			uniqueName += separator + strconv.Itoa(ith)
+		}
+		if !s.taken[uniqueName] {
+			s.taken[uniqueName] = true
+			return uniqueName
+		}
+	}
+}
+
 // AddStd is a convenience function for adding standard library packages
 func (s *Set) AddStd(pkgs ...string) {
 	for _, pkg := range pkgs {
@@ -47,14 +71,8 @@ func (s *Set) Add(path string) string {
 		s.paths[path] = reserved
 		return reserved
 	}
-	assumed := AssumedName(path)
-	ith := s.names[assumed]
-	uniqueName := assumed
-	if ith > 0 {
-		uniqueName += strconv.Itoa(ith)
-	}
+	uniqueName := s.unique(AssumedName(path))
 	s.paths[path] = uniqueName
-	s.names[assumed]++
 	return uniqueName
 }
 
@@ -68,13 +86,8 @@ func (s *Set) AddNamed(name, path string) string {
 		s.paths[path] = reserved
 		return reserved
 	}
-	ith := s.names[name]
-	uniqueName := name
-	if ith > 0 {
-		uniqueName += strconv.Itoa(ith)
-	}
+	uniqueName := s.unique(name)
 	s.paths[path] = uniqueName
-	s.names[name]++
 	return uniqueName
 }
 
@@ -84,14 +97,8 @@ func (s *Set) Reserve(path string) string {
 	if name, ok := s.paths[path]; ok {
 		return name
 	}
-	name := AssumedName(path)
-	ith := s.names[name]
-	uniqueName := name
-	if ith > 0 {
-		uniqueName += strconv.Itoa(ith)
-	}
+	uniqueName := s.unique(AssumedName(path))
 	s.reserved[path] = uniqueName
-	s.names[name]++
 	return uniqueName
 }
 
@@ -120,6 +127,12 @@ type Import struct {
 	Path string `json:"path,omitempty"`
 }
 
+// Alias returns the identifier that was assigned to the import, which may
+// differ from the preferred name if there was a conflict
+func (i *Import) Alias() string {
+	return i.Name
+}
+
 // AssumedName returns the assumed package name of an import path.
 // It does this using only string parsing of the import path.
 // It picks the last element of the path that does not look like a major
@@ -22,6 +22,23 @@ func TestAddNamed(t *testing.T) {
 	is.Equal(im.AddNamed("www", "net/http"), "www")
 	is.Equal(im.AddNamed("www", "hop/http"), "www1")
 	is.Equal(im.AddNamed("v8", "app.com/js/v8"), "v8")
+	is.Equal(im.AddNamed("v8", "rogchap.com/v8go"), "v8_1")
+}
+
+func TestAddNamedConflict(t *testing.T) {
+	is := is.New(t)
+	im := imports.New()
+	is.Equal(im.AddNamed("rand", "crypto/rand"), "rand")
+	is.Equal(im.AddNamed("rand", "math/rand"), "rand1")
+	is.Equal(im.AddNamed("http1", "app.com/http1"), "http1")
+	is.Equal(im.Add("net/http"), "http")
+	// Skips names that were taken by another import
+	is.Equal(im.Add("hop/http"), "http2")
+	is.Equal(im.Reserve("app.com/rand1"), "rand1_1")
+	list := im.List()
+	is.Equal(len(list), 5)
+	is.Equal(list[3].Path, "math/rand")
+	is.Equal(list[3].Alias(), "rand1")
 }
 
 func TestReserveBefore(t *testing.T) {
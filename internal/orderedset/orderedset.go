@@ -0,0 +1,45 @@
+package orderedset
+
+import "container/list"
+
+// NewSet creates an empty set that preserves insertion order
+func NewSet[T comparable]() *OrderedSet[T] {
+	return &OrderedSet[T]{
+		list:  list.New(),
+		items: map[T]*list.Element{},
+	}
+}
+
+// OrderedSet is a set that preserves insertion order
+type OrderedSet[T comparable] struct {
+	list  *list.List
+	items map[T]*list.Element
+}
+
+// Add an item to the set. Adding an item that's already in the set is a no-op.
+func (s *OrderedSet[T]) Add(item T) {
+	if _, ok := s.items[item]; ok {
+		return
+	}
+	s.items[item] = s.list.PushBack(item)
+}
+
+// Contains returns true if the item is in the set
+func (s *OrderedSet[T]) Contains(item T) bool {
+	_, ok := s.items[item]
+	return ok
+}
+
+// Slice returns the items in insertion order
+func (s *OrderedSet[T]) Slice() []T {
+	items := make([]T, 0, s.list.Len())
+	for e := s.list.Front(); e != nil; e = e.Next() {
+		items = append(items, e.Value.(T))
+	}
+	return items
+}
+
+// Len returns the number of items in the set
+func (s *OrderedSet[T]) Len() int {
+	return s.list.Len()
+}
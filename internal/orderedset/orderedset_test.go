@@ -0,0 +1,33 @@
+package orderedset_test
+
+import (
+	"testing"
+
+	"github.com/livebud/bud/internal/is"
+	"github.com/livebud/bud/internal/orderedset"
+)
+
+func TestSet(t *testing.T) {
+	is := is.New(t)
+	set := orderedset.NewSet[int]()
+	is.Equal(set.Len(), 0)
+	is.Equal(set.Slice(), []int{})
+	set.Add(3)
+	set.Add(1)
+	set.Add(3)
+	set.Add(2)
+	is.Equal(set.Len(), 3)
+	is.Equal(set.Slice(), []int{3, 1, 2})
+	is.True(set.Contains(1))
+	is.True(!set.Contains(4))
+}
+
+func TestSetStruct(t *testing.T) {
+	is := is.New(t)
+	type key struct{ a, b string }
+	set := orderedset.NewSet[key]()
+	set.Add(key{"a", "b"})
+	set.Add(key{"b", "a"})
+	set.Add(key{"a", "b"})
+	is.Equal(set.Slice(), []key{{"a", "b"}, {"b", "a"}})
+}
@@ -1,14 +1,10 @@
 package orderedset
 
+// Strings removes duplicates from the list while preserving order
 func Strings(list ...string) []string {
-	seen := map[string]struct{}{}
-	set := make([]string, 0, len(list))
+	set := NewSet[string]()
 	for _, item := range list {
-		if _, ok := seen[item]; ok {
-			continue
-		}
-		seen[item] = struct{}{}
-		set = append(set, item)
+		set.Add(item)
 	}
-	return set
+	return set.Slice()
 }
@@ -1,12 +1,28 @@
 package budfs
 
 import (
+	"bytes"
 	"context"
+	"embed"
+	"encoding/gob"
 	"errors"
 	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"io"
 	"io/fs"
+	"os"
+	"path"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
 
 	"github.com/livebud/bud/package/budfs/linkmap"
 
@@ -14,28 +30,107 @@ import (
 
 	"github.com/livebud/bud/package/virtual"
 
+	"github.com/livebud/bud/internal/dag"
 	"github.com/livebud/bud/internal/dsync"
 	"github.com/livebud/bud/internal/glob"
 	"github.com/livebud/bud/internal/once"
 	"github.com/livebud/bud/internal/orderedset"
 	"github.com/livebud/bud/internal/valid"
+	"github.com/livebud/bud/package/budfs/internal/geninput"
 	"github.com/livebud/bud/package/budfs/mergefs"
 	"github.com/livebud/bud/package/budfs/treefs"
 	"github.com/livebud/bud/package/log"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
 )
 
+// Version of the filesystem. Version is bumped when the cache format changes,
+// so caches written by other versions are rejected.
+const Version = "1"
+
+// ErrNotGenerated can be returned by generators that need more information
+// before they can produce output. Unlike fs.ErrNotExist, callers may retry
+// later.
+var ErrNotGenerated = errors.New("not yet generated")
+
+// ErrNotMounted is returned when unmounting a path that isn't a mount point
+var ErrNotMounted = errors.New("budfs: not mounted")
+
 func New(fsys fs.FS, log log.Interface) *FileSystem {
-	cache := vcache.New()
+	cache := vcache.LRU(0)
 	node := treefs.New(".")
 	merged := mergefs.Merge(node, fsys)
-	return &FileSystem{
-		cache:  cache,
-		closer: new(once.Closer),
-		fsys:   merged,
-		node:   node,
-		log:    log,
-		lmap:   linkmap.New(log),
+	alog := newAtomicLog(log)
+	f := &FileSystem{
+		cache:     cache,
+		closer:    new(once.Closer),
+		ctx:       context.Background(),
+		groups:    &groups{m: map[string]*GeneratorGroup{}},
+		fsys:      merged,
+		node:      node,
+		log:       alog,
+		lmap:      linkmap.New(alog),
+		deps:      dag.New(),
+		tlog:      newTransactionLog(DefaultTransactionLogSize),
+		async:     &asyncChange{},
+		subs:      &subscribers{fns: map[int]func([]string){}},
+		overrides: &overrides{files: map[string]*virtual.File{}},
+		clock:     time.Now,
+		layers: []layer{
+			{node, PriorityGenerated},
+			{fsys, PrioritySource},
+		},
+	}
+	f.root = f
+	return f
+}
+
+// Option configures the filesystem
+type Option func(f *FileSystem) error
+
+// WithCache shares cache with other filesystems. See WithSharedCache for the
+// concurrency requirements.
+func WithCache(cache vcache.Cache) Option {
+	return func(f *FileSystem) error {
+		if cache == nil {
+			return fmt.Errorf("budfs: cache must not be nil")
+		}
+		f.WithSharedCache(cache)
+		return nil
+	}
+}
+
+// WithContext binds ctx as the context for opens that don't pass a context
+func WithContext(ctx context.Context) Option {
+	return func(f *FileSystem) error {
+		if ctx == nil {
+			return fmt.Errorf("budfs: context must not be nil")
+		}
+		f.ctx = ctx
+		return nil
+	}
+}
+
+// NewWithOptions is like New, but configures the filesystem with options.
+// Returns an error if any of the options are invalid.
+func NewWithOptions(fsys fs.FS, log log.Interface, options ...Option) (*FileSystem, error) {
+	f := New(fsys, log)
+	for _, option := range options {
+		if err := option(f); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// MustNew is like NewWithOptions, but panics if any of the options are
+// invalid. Use MustNew in program setup code that can't recover.
+func MustNew(fsys fs.FS, log log.Interface, options ...Option) *FileSystem {
+	f, err := NewWithOptions(fsys, log, options...)
+	if err != nil {
+		panic(err)
 	}
+	return f
 }
 
 type FileSystem struct {
@@ -44,7 +139,174 @@ type FileSystem struct {
 	fsys   fs.FS
 	node   *treefs.Node
 	lmap   *linkmap.Map
-	log    log.Interface
+	log    *atomicLog
+	less   func(a, b string) bool
+	deps   *dag.Graph
+	root   *FileSystem     // Filesystem without a namespace
+	ctx    context.Context // Context for opens that don't pass a context
+	groups *groups         // Named groups of generators
+	prefix string          // Prefix for namespaced filesystems
+	layers []layer         // Layers in priority order
+
+	interceptors []func(path string, next func() (fs.File, error)) (fs.File, error)
+	errorHandler func(path string, err error) (fs.File, error)
+	opener       func(name string) (string, map[string]string)
+	notifier     Notifier
+	tlog         *transactionLog
+	async        *asyncChange
+	subs         *subscribers
+	overrides    *overrides
+	clock        func() time.Time
+
+	// Counters for profiling. Only the root filesystem's counters are used.
+	opens     int64
+	generates int64
+
+	frozen int32 // Set by Freeze on the root filesystem
+}
+
+const (
+	// PriorityGenerated is the priority of the generated files
+	PriorityGenerated = 100
+	// PrioritySource is the priority of the filesystem passed into New
+	PrioritySource = 0
+)
+
+type layer struct {
+	fsys     fs.FS
+	priority int
+}
+
+// Overlay adds a filesystem layer. Layers with a higher priority win when the
+// same path exists in multiple layers. Use a priority between PrioritySource
+// and PriorityGenerated to let files override the sources but not the
+// generated files. Overlay should be called before the filesystem is used.
+func (f *FileSystem) Overlay(fsys fs.FS, priority int) {
+	root := f.root
+	root.layers = append(root.layers, layer{fsys, priority})
+	// Layers with the same priority keep their registration order
+	sort.SliceStable(root.layers, func(i, j int) bool {
+		return root.layers[i].priority > root.layers[j].priority
+	})
+	fileSystems := make([]fs.FS, len(root.layers))
+	for i, layer := range root.layers {
+		fileSystems[i] = layer.fsys
+	}
+	root.fsys = mergefs.Merge(fileSystems...)
+}
+
+// WithContext returns a shallow copy of the filesystem that opens files with
+// ctx, passing ctx through to generators. Generators and caches are shared with
+// the original filesystem.
+func (f *FileSystem) WithContext(ctx context.Context) *FileSystem {
+	c := *f
+	c.ctx = ctx
+	return &c
+}
+
+// WithOpener returns a copy of the filesystem that calls fn before each open to
+// log the open with extra attributes, such as a trace or request ID. fn returns
+// the name to log along with the attributes. The file that's opened is always
+// the original name.
+func (f *FileSystem) WithOpener(fn func(name string) (modifiedName string, attrs map[string]string)) *FileSystem {
+	c := *f
+	c.opener = fn
+	return &c
+}
+
+// logOpen logs the open with the attributes returned by the opener
+func (f *FileSystem) logOpen(name string) {
+	logged, attrs := f.opener(name)
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fields := []interface{}{"path", logged}
+	for _, key := range keys {
+		fields = append(fields, key, attrs[key])
+	}
+	f.log.Debug("budfs: open", fields...)
+}
+
+// SetLogger replaces the logger used by subsequent generator calls, cache
+// operations and syncs. SetLogger is safe to call concurrently.
+func (f *FileSystem) SetLogger(log log.Interface) {
+	f.log.Store(log)
+}
+
+// Namespace returns a filesystem that prefixes the paths of every generator
+// registered through it. The namespaced filesystem shares the cache and
+// generators with the parent filesystem, so both Open(prefix+"/foo") on the
+// parent and Open("foo") on the namespace work. Generators still see paths
+// relative to the root of the filesystem.
+func (f *FileSystem) Namespace(prefix string) *FileSystem {
+	ns := *f
+	ns.prefix = f.path(prefix)
+	return &ns
+}
+
+// relative removes the namespace from path. Returns false if the path is
+// outside of the namespace.
+func (f *FileSystem) relative(path string) (string, bool) {
+	if f.prefix == "" {
+		return path, true
+	} else if path == f.prefix {
+		return ".", true
+	} else if strings.HasPrefix(path, f.prefix+"/") {
+		return strings.TrimPrefix(path, f.prefix+"/"), true
+	}
+	return "", false
+}
+
+// path prefixes name with the namespace
+func (f *FileSystem) path(name string) string {
+	if f.prefix == "" {
+		return name
+	}
+	return path.Join(f.prefix, name)
+}
+
+// Intercept registers fn to wrap every open, including opens that are served
+// from the cache. Calling next continues opening the file. Interceptors are
+// chained in the order they're registered. Intercept should be called before
+// the filesystem is used.
+func (f *FileSystem) Intercept(fn func(path string, next func() (fs.File, error)) (fs.File, error)) {
+	f.root.interceptors = append(f.root.interceptors, fn)
+}
+
+// SetClock replaces the clock used to timestamp generated files, transaction
+// log entries and the last access of LRU cache entries. The clock defaults to
+// time.Now. SetClock should be called before the filesystem is used.
+func (f *FileSystem) SetClock(clock func() time.Time) {
+	f.root.clock = clock
+	f.root.tlog.mu.Lock()
+	f.root.tlog.clock = clock
+	f.root.tlog.mu.Unlock()
+	if lru, ok := f.root.cache.(*vcache.LRUCache); ok {
+		lru.SetClock(clock)
+	}
+}
+
+// InterceptPrefix calls fn for every open of prefix or a path within prefix.
+// When fn returns true, its data is returned without running the generator.
+// Otherwise the file is opened as usual. Use InterceptPrefix in tests to mock
+// out whole directories of generated files.
+func (f *FileSystem) InterceptPrefix(prefix string, fn func(path string) ([]byte, bool)) {
+	prefix = f.path(prefix)
+	f.Intercept(func(path string, next func() (fs.File, error)) (fs.File, error) {
+		if prefix != "." && path != prefix && !strings.HasPrefix(path, prefix+"/") {
+			return next()
+		}
+		data, ok := fn(path)
+		if !ok {
+			return next()
+		}
+		return virtual.New(&virtual.File{
+			Path: path,
+			Data: data,
+		}), nil
+	})
 }
 
 type File struct {
@@ -69,6 +331,13 @@ func (f *File) Mode() fs.FileMode {
 	return f.node.Mode()
 }
 
+func init() {
+	geninput.New = func(fsys interface{}, ctx context.Context, dir, target string) (interface{}, interface{}) {
+		f := fsys.(*FileSystem)
+		return &fileSystem{ctx, f.root, f.lmap.Scope(target), dir}, &File{nil, treefs.New(dir), target}
+	}
+}
+
 type FS interface {
 	fs.FS
 	fs.ReadDirFS
@@ -76,12 +345,32 @@ type FS interface {
 	Link(to string)
 	Context() context.Context
 	Defer(func() error)
+	Require(path string) ([]byte, error)
+	ReadAll(pattern string) (map[string][]byte, error)
+	OpenWithFallback(name string, fallbacks ...fs.FS) (fs.File, error)
+}
+
+// RequiredFileError is returned by FS.Require when a required file doesn't
+// exist
+type RequiredFileError struct {
+	Generator string // Path of the generator requiring the file
+	Path      string // Path of the required file
+	Err       error
+}
+
+func (e *RequiredFileError) Error() string {
+	return fmt.Sprintf("budfs: %q generator requires %q but it does not exist. %s", e.Generator, e.Path, e.Err)
+}
+
+func (e *RequiredFileError) Unwrap() error {
+	return e.Err
 }
 
 type Dir struct {
 	fsys   *FileSystem
 	node   *treefs.Node
 	target string
+	dirg   *dirGenerator // Nil when the directory can't emit files
 }
 
 func (d *Dir) Target() string {
@@ -101,7 +390,7 @@ func (d *Dir) Mode() fs.FileMode {
 }
 
 func (d *Dir) GenerateFile(path string, fn func(fsys FS, file *File) error) {
-	fileg := &fileGenerator{d.fsys, fn, nil}
+	fileg := &fileGenerator{fsys: d.fsys, fn: fn}
 	fileg.node = d.node.FileGenerator(path, fileg)
 }
 
@@ -109,8 +398,21 @@ func (d *Dir) FileGenerator(path string, generator FileGenerator) {
 	d.GenerateFile(path, generator.GenerateFile)
 }
 
+// GenerateFileFromTemplate generates a file by executing tmpl with data
+func (d *Dir) GenerateFileFromTemplate(path string, tmpl *template.Template, data interface{}) {
+	d.GenerateFile(path, templateFile(tmpl, func(FS) (interface{}, error) {
+		return data, nil
+	}))
+}
+
+// GenerateFileFromTemplateFunc generates a file by executing tmpl with the
+// result of dataFn. Use this when the data depends on other files.
+func (d *Dir) GenerateFileFromTemplateFunc(path string, tmpl *template.Template, dataFn func(fsys FS) (interface{}, error)) {
+	d.GenerateFile(path, templateFile(tmpl, dataFn))
+}
+
 func (d *Dir) GenerateDir(dir string, fn func(fsys FS, dir *Dir) error) {
-	dirg := &dirGenerator{d.fsys, fn, nil}
+	dirg := &dirGenerator{fsys: d.fsys, fn: fn}
 	dirg.node = d.node.DirGenerator(dir, dirg)
 }
 
@@ -118,13 +420,39 @@ func (d *Dir) DirGenerator(dir string, generator DirGenerator) {
 	d.GenerateDir(dir, generator.GenerateDir)
 }
 
+// EmitFile adds a file to the directory without registering a generator. The
+// file is only listed until the directory is generated again, so use this for
+// lists of files that change on every run. EmitFile is only supported within
+// GenerateDir.
+func (d *Dir) EmitFile(name string, data []byte) error {
+	if d.dirg == nil {
+		return fmt.Errorf("budfs: unable to emit %q. emitting files is only supported within GenerateDir", name)
+	} else if !fs.ValidPath(name) || name == "." || strings.Contains(name, "/") {
+		return fmt.Errorf("budfs: unable to emit %q. %w", name, fs.ErrInvalid)
+	} else if _, ok := d.node.Find(name); ok {
+		return fmt.Errorf("budfs: unable to emit %q. %w", name, fs.ErrExist)
+	}
+	d.dirg.emit(path.Join(d.node.Path(), name), data)
+	return nil
+}
+
 type mountGenerator struct {
+	mu   sync.RWMutex
 	dir  string
 	fsys fs.FS
 }
 
 func (g *mountGenerator) Generate(target string) (fs.File, error) {
-	return g.fsys.Open(relativePath(g.dir, target))
+	g.mu.RLock()
+	dir := g.dir
+	g.mu.RUnlock()
+	return g.fsys.Open(relativePath(dir, target))
+}
+
+func (g *mountGenerator) rebase(from, to string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.dir = rebasePath(g.dir, from, to)
 }
 
 func (d *Dir) Mount(mount fs.FS) error {
@@ -133,7 +461,7 @@ func (d *Dir) Mount(mount fs.FS) error {
 		return fmt.Errorf("budfs: mount error. %w", err)
 	}
 	// Wrap mount in the existing generator cache
-	mountg := &mountGenerator{d.node.Path(), mount}
+	mountg := &mountGenerator{dir: d.node.Path(), fsys: mount}
 	// Loop over the first level and add the mount, allowing us to mount "."
 	// on an existing directory
 	for _, de := range des {
@@ -146,6 +474,121 @@ func (d *Dir) Mount(mount fs.FS) error {
 	return nil
 }
 
+// MountEmbed mounts an embedded filesystem within the directory. Unlike Mount,
+// generators that are already registered within the directory take priority
+// over the embedded files. Embedded paths keep their directory prefix, so
+// embedding "public" mounts the files under "public/".
+func MountEmbed(dir *Dir, fsys embed.FS) error {
+	mountg := &mountGenerator{dir: dir.node.Path(), fsys: fsys}
+	if err := mountEmbed(dir, fsys, mountg, "."); err != nil {
+		return fmt.Errorf("budfs: mount embed error. %w", err)
+	}
+	return nil
+}
+
+// mountEmbed mounts the entries within the embedded directory. Directories
+// that already contain generators are descended into, so the listing merges
+// the embedded files with the generated ones.
+func mountEmbed(dir *Dir, fsys embed.FS, mountg *mountGenerator, rel string) error {
+	des, err := fsys.ReadDir(rel)
+	if err != nil {
+		return err
+	}
+	for _, de := range des {
+		name := path.Join(rel, de.Name())
+		if node, ok := dir.node.Find(name); ok {
+			// Generators take priority over the embedded files
+			if _, ok := node.Generator(); ok {
+				continue
+			}
+			if de.IsDir() {
+				if err := mountEmbed(dir, fsys, mountg, name); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if de.IsDir() {
+			dir.node.DirGenerator(name, mountg)
+			continue
+		}
+		dir.node.FileGenerator(name, mountg)
+	}
+	return nil
+}
+
+// OpenDir returns a handle for registering generators within the directory at
+// name, outside of a GenerateDir callback. Missing directories are created.
+// Returns an error if name is within a file generator.
+func (f *FileSystem) OpenDir(name string) (*Dir, error) {
+	f.mustNotBeFrozen(name)
+	if !fs.ValidPath(name) {
+		return nil, fmt.Errorf("budfs: unable to open dir %q. %w", name, fs.ErrInvalid)
+	}
+	target := f.path(name)
+	// Ensure we're not creating directories within a file generator
+	if target != "." {
+		segments := strings.Split(target, "/")
+		for i := range segments {
+			node, ok := f.node.Find(strings.Join(segments[:i+1], "/"))
+			if !ok {
+				break
+			} else if !node.Mode().IsDir() {
+				return nil, fmt.Errorf("budfs: unable to open dir %q. %q is a file", name, node.Path())
+			}
+		}
+	}
+	node := f.node.MkdirAll(target)
+	return &Dir{f.root, node, node.Path(), nil}, nil
+}
+
+// Mount an external filesystem at path without needing a containing directory
+// generator. This is useful for mounting static assets, embedded filesystems
+// and remote filesystems.
+func (f *FileSystem) Mount(path string, fsys fs.FS) error {
+	f.mustNotBeFrozen(path)
+	if !fs.ValidPath(path) || path == "." {
+		return fmt.Errorf("budfs: unable to mount %q. %w", path, fs.ErrInvalid)
+	}
+	target := f.path(path)
+	f.node.DirGenerator(target, &mountGenerator{dir: target, fsys: fsys})
+	return nil
+}
+
+// Unmount removes the filesystem mounted at path, along with any cached files
+// and links under path. Returns ErrNotMounted if path isn't a mount point.
+func (f *FileSystem) Unmount(path string) error {
+	target := f.path(path)
+	node, ok := f.node.Find(target)
+	if !ok {
+		return fmt.Errorf("budfs: unable to unmount %q. %w", path, ErrNotMounted)
+	}
+	generator, ok := node.Generator()
+	if !ok {
+		return fmt.Errorf("budfs: unable to unmount %q. %w", path, ErrNotMounted)
+	}
+	if mountg, ok := generator.(*mountGenerator); !ok || mountg.dir != target {
+		return fmt.Errorf("budfs: unable to unmount %q. %w", path, ErrNotMounted)
+	}
+	f.node.Delete(strings.Split(target, "/")...)
+	within := func(p string) bool {
+		return p == target || strings.HasPrefix(p, target+"/")
+	}
+	f.cache.Range(func(path string, _ virtual.Entry) bool {
+		if within(path) {
+			f.cache.Delete(path)
+		}
+		return true
+	})
+	f.lmap.Range(func(path string, _ *linkmap.List) bool {
+		if within(path) {
+			f.lmap.Delete(path)
+		}
+		return true
+	})
+	return nil
+}
+
 type FileGenerator interface {
 	GenerateFile(fsys FS, file *File) error
 }
@@ -178,172 +621,1855 @@ func (e *EmbedFile) GenerateFile(fsys FS, file *File) error {
 }
 
 func (f *FileSystem) Open(name string) (fs.File, error) {
-	file, err := f.fsys.Open(name)
-	if err != nil {
-		return nil, fmt.Errorf("budfs: open %q. %w", name, err)
-	}
-	return file, nil
+	return f.OpenContext(f.ctx, name)
 }
 
-func (f *FileSystem) Close() error {
-	return f.closer.Close()
+// OpenContext opens a file, passing ctx through to generators that accept a
+// context, such as those registered with ServeFileContext.
+func (f *FileSystem) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	if f.opener != nil {
+		f.logOpen(name)
+	}
+	file, err := f.openIntercepted(ctx, name)
+	if err != nil && f.root.errorHandler != nil && !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, ErrNotGenerated) {
+		return f.root.errorHandler(f.path(name), err)
+	}
+	return file, err
 }
 
-type fileGenerator struct {
-	fsys *FileSystem
-	fn   func(fsys FS, file *File) error
-	node *treefs.Node
+// OpenWithFallback opens name, falling back to opening name in each of the
+// fallbacks in order when it doesn't exist. Use this to provide defaults, such
+// as default templates, that can be overridden.
+func (f *FileSystem) OpenWithFallback(name string, fallbacks ...fs.FS) (fs.File, error) {
+	return openWithFallback(f.Open, name, fallbacks)
 }
 
-func (g *fileGenerator) Generate(target string) (fs.File, error) {
-	if entry, ok := g.fsys.cache.Get(target); ok {
-		return virtual.New(entry), nil
-	}
-	fctx := &fileSystem{context.TODO(), g.fsys, g.fsys.lmap.Scope(target)}
-	file := &File{nil, g.node, target}
-	g.fsys.log.Debug("budfs: running file generator function", "target", target)
-	if err := g.fn(fctx, file); err != nil {
-		return nil, err
+func openWithFallback(open func(name string) (fs.File, error), name string, fallbacks []fs.FS) (fs.File, error) {
+	file, err := open(name)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return file, err
 	}
-	vfile := &virtual.File{
-		Path: g.node.Path(),
-		Mode: g.node.Mode(),
-		Data: file.Data,
+	for _, fallback := range fallbacks {
+		file, ferr := fallback.Open(name)
+		if ferr == nil {
+			return file, nil
+		} else if !errors.Is(ferr, fs.ErrNotExist) {
+			return nil, fmt.Errorf("budfs: unable to open fallback %q. %w", name, ferr)
+		}
 	}
-	g.fsys.cache.Set(target, vfile)
-	return virtual.New(vfile), nil
+	return nil, err
 }
 
-func (f *FileSystem) GenerateFile(path string, fn func(fsys FS, file *File) error) {
-	fileg := &fileGenerator{f, fn, nil}
-	fileg.node = f.node.FileGenerator(path, fileg)
+// ErrorHandler registers fn to recover from generator errors. When a generator
+// fails, fn is called with the path and error, and its result is returned
+// instead. This is useful for serving error pages or placeholder files during
+// development. Missing files aren't passed to fn. Generators that open a
+// failing file still see the original error.
+func (f *FileSystem) ErrorHandler(fn func(path string, err error) (fs.File, error)) {
+	f.root.errorHandler = fn
 }
 
-func (f *FileSystem) FileGenerator(path string, generator FileGenerator) {
-	f.GenerateFile(path, generator.GenerateFile)
+// openIntercepted opens the file through the interceptors without recovering
+// from errors. Generators open files this way, so their output isn't built
+// from error placeholders.
+func (f *FileSystem) openIntercepted(ctx context.Context, name string) (fs.File, error) {
+	atomic.AddInt64(&f.root.opens, 1)
+	name = f.path(name)
+	next := func() (fs.File, error) {
+		return f.open(ctx, name)
+	}
+	// Chain the interceptors so the first one registered runs first
+	interceptors := f.root.interceptors
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		intercept, inner := interceptors[i], next
+		next = func() (fs.File, error) {
+			return intercept(name, inner)
+		}
+	}
+	return next()
 }
 
-type dirGenerator struct {
-	fsys *FileSystem
-	fn   func(fsys FS, dir *Dir) error
-	node *treefs.Node
+func (f *FileSystem) open(ctx context.Context, name string) (fs.File, error) {
+	if vfile, ok := f.root.overrides.get(name); ok {
+		return virtual.New(vfile), nil
+	}
+	file, err := openContext(ctx, f.root.fsys, name)
+	if err != nil {
+		// Keep ErrNotGenerated matchable so callers can retry
+		if errors.Is(err, ErrNotGenerated) {
+			return nil, fmt.Errorf("budfs: unable to open %q. %w", name, ErrNotGenerated)
+		}
+		return nil, fmt.Errorf("budfs: open %q. %w", name, err)
+	}
+	return file, nil
 }
 
-func (g *dirGenerator) Generate(target string) (fs.File, error) {
-	if _, ok := g.fsys.cache.Get(g.node.Path()); ok {
-		return g.node.Open(target)
+// DeclareDepends declares that dependent depends on dependency. When dependent
+// is generated, dependency is always regenerated first, even if it's cached.
+func (f *FileSystem) DeclareDepends(dependent, dependency string) {
+	f.deps.Link(f.path(dependent), f.path(dependency))
+}
+
+// generateDependencies regenerates the declared dependencies of target
+func (f *FileSystem) generateDependencies(fsys *fileSystem, target string) error {
+	for _, dependency := range f.deps.Children(target) {
+		if _, err := f.deps.ShortestPath(dependency, target); err == nil {
+			return fmt.Errorf("budfs: dependency cycle between %q and %q", target, dependency)
+		}
+		f.log.Debug("budfs: generating dependency", "target", target, "dependency", dependency)
+		f.cache.Delete(dependency)
+		file, err := fsys.Open(dependency)
+		if err != nil {
+			return fmt.Errorf("budfs: unable to generate dependency %q of %q. %w", dependency, target, err)
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
 	}
-	fctx := &fileSystem{context.TODO(), g.fsys, g.fsys.lmap.Scope(target)}
-	dir := &Dir{g.fsys, g.node, target}
-	g.fsys.log.Debug("budfs: running dir generator function", "path", g.node.Path(), "target", target)
-	if err := g.fn(fctx, dir); err != nil {
-		return nil, err
+	return nil
+}
+
+// SetMaxCacheBytes sets a memory budget for the generator cache. When the
+// cached file data exceeds maxBytes, the least recently used entries are
+// evicted. A maxBytes of zero or less removes the budget. SetMaxCacheBytes has
+// no effect on a shared cache that isn't an LRU cache.
+func (f *FileSystem) SetMaxCacheBytes(maxBytes int64) {
+	if lru, ok := f.cache.(*vcache.LRUCache); ok {
+		lru.SetMaxBytes(maxBytes)
 	}
-	g.fsys.cache.Set(g.node.Path(), &virtual.Dir{
-		Path:    g.node.Path(),
-		Mode:    g.node.Mode(),
-		Entries: g.node.Entries(),
-	})
-	return g.node.Open(target)
 }
 
-func (f *FileSystem) GenerateDir(path string, fn func(fsys FS, dir *Dir) error) {
-	dirg := &dirGenerator{f, fn, nil}
-	dirg.node = f.node.DirGenerator(path, dirg)
+// OpCount returns the number of files opened, whether they were served from
+// the cache or generated
+func (f *FileSystem) OpCount() int64 {
+	return atomic.LoadInt64(&f.root.opens)
 }
 
-func (f *FileSystem) DirGenerator(path string, generator DirGenerator) {
-	f.GenerateDir(path, generator.GenerateDir)
+// GenerateCount returns the number of times a generator ran because the file
+// wasn't cached
+func (f *FileSystem) GenerateCount() int64 {
+	return atomic.LoadInt64(&f.root.generates)
 }
 
-type fileServer struct {
-	fsys *FileSystem
-	fn   func(fsys FS, file *File) error
-	node *treefs.Node
+// ResetCounters resets the counts returned by OpCount and GenerateCount
+func (f *FileSystem) ResetCounters() {
+	atomic.StoreInt64(&f.root.opens, 0)
+	atomic.StoreInt64(&f.root.generates, 0)
 }
 
-func (g *fileServer) Generate(target string) (fs.File, error) {
-	if entry, ok := g.fsys.cache.Get(target); ok {
-		return virtual.New(entry), nil
+// CacheBytes returns the size of the cached file data in bytes
+func (f *FileSystem) CacheBytes() int64 {
+	if lru, ok := f.cache.(*vcache.LRUCache); ok {
+		return lru.Size()
 	}
-	rel := relativePath(g.node.Path(), target)
-	if rel == "." {
-		return nil, &fs.PathError{
-			Op:   "open",
-			Path: g.node.Path(),
-			Err:  fs.ErrInvalid,
+	size := int64(0)
+	f.cache.Range(func(path string, entry virtual.Entry) bool {
+		if file, ok := entry.(*virtual.File); ok {
+			size += int64(len(file.Data))
 		}
-	}
-	fctx := &fileSystem{context.TODO(), g.fsys, g.fsys.lmap.Scope(target)}
-	// File differs slightly than others because g.node.Path() is the directory
-	// path, but we want the target path for serving files.
-	file := &File{nil, g.node, target}
-	g.fsys.log.Debug("budfs: running file server function", "path", g.node.Path(), "target", target)
-	if err := g.fn(fctx, file); err != nil {
-		return nil, err
-	}
-	vfile := &virtual.File{
-		Path: target,
-		Mode: fs.FileMode(0),
-		Data: file.Data,
-	}
-	g.fsys.cache.Set(target, vfile)
-	return virtual.New(vfile), nil
+		return true
+	})
+	return size
 }
 
-func (f *FileSystem) ServeFile(dir string, fn func(fsys FS, file *File) error) {
-	fileg := &fileServer{f, fn, nil}
-	fileg.node = f.node.DirGenerator(dir, fileg)
+// WithSharedCache replaces the filesystem's private cache with a cache that
+// can be shared across filesystems, so expensive generators only run once
+// across instances. WithSharedCache must be called before the filesystem is
+// used or namespaced.
+//
+// The shared cache must be safe for concurrent use. Entries are keyed by path,
+// so each filesystem sharing the cache must register generators that produce
+// the same output for the same path. Calling Change on one filesystem evicts
+// the changed paths for every filesystem, but only the dependents linked in
+// that filesystem are evicted.
+func (f *FileSystem) WithSharedCache(cache vcache.Cache) *FileSystem {
+	f.root.cache = cache
+	f.cache = cache
+	return f
 }
 
-func (f *FileSystem) FileServer(dir string, generator FileGenerator) {
-	f.ServeFile(dir, generator.GenerateFile)
+// Version returns the version of the filesystem
+func (f *FileSystem) Version() string {
+	return Version
 }
 
-// Sync the overlay to the filesystem
-func (f *FileSystem) Sync(writable virtual.FS, to string) error {
-	// Temporarily replace the underlying fs.FS with a cached fs.FS
-	cache := vcache.New()
-	fsys := f.fsys
-	f.fsys = vcache.Wrap(cache, fsys, f.log)
-	err := dsync.To(f.fsys, writable, to)
-	f.fsys = fsys
-	return err
+// cacheHeader precedes the cached files in a cache dump
+type cacheHeader struct {
+	Version string
 }
 
-// Change updates the cache
-func (f *FileSystem) Change(paths ...string) {
-	for i := 0; i < len(paths); i++ {
-		path := paths[i]
-		if f.cache.Has(path) {
-			f.log.Debug("budfs: cache", "delete", path)
-			f.cache.Delete(path)
+// CacheDump writes the cached files to w using encoding/gob. Cached directory
+// listings aren't written since they're cheap to regenerate.
+func (f *FileSystem) CacheDump(w io.Writer) error {
+	files := []*virtual.File{}
+	f.cache.Range(func(path string, entry virtual.Entry) bool {
+		if file, ok := entry.(*virtual.File); ok {
+			files = append(files, file)
 		}
-		f.lmap.Range(func(genPath string, fns *linkmap.List) bool {
-			if f.cache.Has(genPath) && fns.Check(path) {
-				paths = append(paths, genPath)
-			}
-			return true
-		})
+		return true
+	})
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(cacheHeader{Version}); err != nil {
+		return fmt.Errorf("budfs: unable to dump cache. %w", err)
 	}
+	if err := enc.Encode(files); err != nil {
+		return fmt.Errorf("budfs: unable to dump cache. %w", err)
+	}
+	return nil
 }
 
-type fileSystem struct {
-	ctx  context.Context
-	fsys *FileSystem
-	link *linkmap.List
-}
-
-var _ FS = (*fileSystem)(nil)
-
-// Open implements fs.FS
-func (f *fileSystem) Open(name string) (fs.File, error) {
-	file, err := f.fsys.Open(name)
-	if err != nil {
-		return nil, err
+// CacheLoad reads files written by CacheDump back into the cache. Files whose
+// paths are no longer served by a generator are skipped. Caches written by a
+// different version of budfs are rejected. Files within a
+// directory generator are restored once the directory generator runs. Links aren't part of
+// the dump, so call Change with any paths that changed since the dump was
+// written.
+func (f *FileSystem) CacheLoad(r io.Reader) error {
+	dec := gob.NewDecoder(r)
+	var header cacheHeader
+	if err := dec.Decode(&header); err != nil {
+		return fmt.Errorf("budfs: unable to load cache. %w", err)
 	}
-	f.link.Link("open", name)
-	return file, nil
+	if header.Version != Version {
+		return fmt.Errorf("budfs: unable to load cache with version %q, expected version %q", header.Version, Version)
+	}
+	var files []*virtual.File
+	if err := dec.Decode(&files); err != nil {
+		return fmt.Errorf("budfs: unable to load cache. %w", err)
+	}
+	for _, file := range files {
+		if !f.root.restorable(file.Path) {
+			f.log.Debug("budfs: skipping unregistered cache entry", "path", file.Path)
+			continue
+		}
+		f.cache.Set(file.Path, file)
+	}
+	return nil
+}
+
+// WarmFromDir populates the cache from files previously written to dir, such
+// as by Sync. Files whose paths aren't served by a generator are skipped. The
+// files are trusted as-is, so call Change with any paths that changed since
+// they were written.
+func (f *FileSystem) WarmFromDir(dir string) error {
+	fsys := os.DirFS(dir)
+	err := fs.WalkDir(fsys, ".", func(path string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if de.IsDir() || !f.root.restorable(path) {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		info, err := de.Info()
+		if err != nil {
+			return err
+		}
+		f.cache.Set(path, &virtual.File{
+			Path:    path,
+			Data:    data,
+			Mode:    info.Mode(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("budfs: unable to warm the cache from %q. %w", dir, err)
+	}
+	return nil
+}
+
+// restorable returns true if the path is served by a generator, either directly
+// or within a directory generator
+func (f *FileSystem) restorable(path string) bool {
+	key, ok := f.cacheKey(path)
+	return ok && (key == path || strings.HasPrefix(path, key+"/"))
+}
+
+// ReadDir implements fs.ReadDirFS, ordering the entries with the comparator
+// set by Reorder.
+func (f *FileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	file, err := f.OpenContext(f.ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("budfs: readdir %q. %w", name, err)
+	}
+	des, err := f.readDir(file)
+	if err != nil {
+		return nil, fmt.Errorf("budfs: readdir %q. %w", name, err)
+	}
+	return des, nil
+}
+
+// readDir reads and closes the opened directory, ordering the entries with the
+// comparator set by Reorder or by name
+func (f *FileSystem) readDir(file fs.File) ([]fs.DirEntry, error) {
+	defer file.Close()
+	dir, ok := file.(fs.ReadDirFile)
+	if !ok {
+		return nil, fs.ErrInvalid
+	}
+	des, err := dir.ReadDir(-1)
+	if err != nil {
+		return nil, err
+	}
+	less := f.root.less
+	if less == nil {
+		less = SortAlphabetical
+	}
+	sort.SliceStable(des, func(i, j int) bool {
+		return less(des[i].Name(), des[j].Name())
+	})
+	return des, nil
+}
+
+// ReadDirPage reads up to limit entries from the named directory, starting
+// after the entry named start. Pass the name of the last entry of the previous
+// page as start to read the next page, or "" to read the first page. A limit
+// of zero or less reads the remaining entries.
+func (f *FileSystem) ReadDirPage(name string, start string, limit int) ([]fs.DirEntry, error) {
+	des, err := f.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	// Accept the path of the entry as well as its name
+	start = path.Base(start)
+	if start != "." && start != "/" {
+		i := 0
+		if f.root.less == nil {
+			// Entries are sorted by name, so the start entry doesn't need to exist
+			i = sort.Search(len(des), func(i int) bool {
+				return des[i].Name() > start
+			})
+		} else {
+			for i < len(des) && des[i].Name() != start {
+				i++
+			}
+			if i < len(des) {
+				i++
+			}
+		}
+		des = des[i:]
+	}
+	if limit > 0 && len(des) > limit {
+		des = des[:limit]
+	}
+	return des, nil
+}
+
+// Reorder sets the comparator used to sort directory entries across the
+// entire filesystem. Reorder should be called before the filesystem is used.
+func (f *FileSystem) Reorder(less func(a, b string) bool) {
+	f.root.less = less
+}
+
+// SortAlphabetical orders entries by name
+func SortAlphabetical(a, b string) bool {
+	return a < b
+}
+
+// SortByExtension orders entries by extension, then by name
+func SortByExtension(a, b string) bool {
+	aext, bext := path.Ext(a), path.Ext(b)
+	if aext != bext {
+		return aext < bext
+	}
+	return a < b
+}
+
+// OpenGroup opens all the paths concurrently with a bounded number of workers.
+// If any path fails to open, the files that were opened are closed and the
+// first error is returned.
+func (f *FileSystem) OpenGroup(paths ...string) ([]fs.File, error) {
+	files := make([]fs.File, len(paths))
+	err := forEach(paths, func(i int, path string) error {
+		file, err := f.Open(path)
+		if err != nil {
+			return err
+		}
+		files[i] = file
+		return nil
+	})
+	if err != nil {
+		for _, file := range files {
+			if file != nil {
+				file.Close()
+			}
+		}
+		return nil, err
+	}
+	return files, nil
+}
+
+// Generate runs the generators for paths concurrently with a bounded number of
+// workers, filling the cache without writing anything to disk. Returns the
+// first error. Unlike opening the paths, errors aren't passed to the error
+// handler, so they can be reported before syncing.
+func (f *FileSystem) Generate(ctx context.Context, paths ...string) error {
+	return forEach(paths, func(_ int, path string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		file, err := f.openIntercepted(ctx, path)
+		if err != nil {
+			return err
+		}
+		return file.Close()
+	})
+}
+
+// MustGenerate opens and reads the file at path, panicking if the file can't
+// be generated. Use MustGenerate in init functions and test setup where there's
+// no way to recover from the error.
+func (f *FileSystem) MustGenerate(ctx context.Context, path string) []byte {
+	data, err := f.readFile(ctx, path)
+	if err != nil {
+		panic(fmt.Errorf("budfs: unable to generate %q. %w", path, err))
+	}
+	return data
+}
+
+func (f *FileSystem) readFile(ctx context.Context, path string) ([]byte, error) {
+	file, err := f.OpenContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// Check runs every registered generator, returning a MultiError with the
+// errors of the generators that failed. Check is a dry run: the cache is
+// emptied so every generator runs, then restored afterwards, so the generated
+// output is discarded. Unlike Generate, Check keeps going after an error, so
+// every failure is reported at once. File servers are skipped because the files
+// they serve can't be listed.
+func (f *FileSystem) Check(ctx context.Context) error {
+	var paths []string
+	f.node.Walk(func(node *treefs.Node) bool {
+		if _, ok := node.Generator(); !ok {
+			return true
+		}
+		// Directories are checked recursively
+		paths = append(paths, node.Path())
+		return false
+	})
+	// Snapshot the cache, so it can be restored after the generators run
+	snapshot := map[string]virtual.Entry{}
+	f.cache.Range(func(path string, entry virtual.Entry) bool {
+		snapshot[path] = entry
+		return true
+	})
+	f.cache.Clear()
+	defer func() {
+		f.cache.Clear()
+		for path, entry := range snapshot {
+			f.cache.Set(path, entry)
+		}
+	}()
+	var errs MultiError
+	for _, path := range paths {
+		errs = append(errs, f.root.check(ctx, path)...)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// check generates the file at path, along with everything within path if
+// it's a directory
+func (f *FileSystem) check(ctx context.Context, name string) (errs []error) {
+	if err := ctx.Err(); err != nil {
+		return []error{err}
+	} else if f.isServer(name) {
+		return nil
+	}
+	file, err := f.openIntercepted(ctx, name)
+	if err != nil {
+		// Sync also skips files that aren't generated
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, ErrNotGenerated) {
+			return nil
+		}
+		return []error{err}
+	}
+	defer file.Close()
+	dir, ok := file.(fs.ReadDirFile)
+	if !ok {
+		return nil
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		return []error{err}
+	} else if !stat.IsDir() {
+		return nil
+	}
+	des, err := dir.ReadDir(-1)
+	if err != nil {
+		return []error{fmt.Errorf("budfs: unable to read directory %q. %w", name, err)}
+	}
+	for _, de := range des {
+		errs = append(errs, f.check(ctx, path.Join(name, de.Name()))...)
+	}
+	return errs
+}
+
+// isServer returns true if path is served by a file server
+func (f *FileSystem) isServer(path string) bool {
+	node, ok := f.node.Find(path)
+	if !ok {
+		return false
+	}
+	generator, _ := node.Generator()
+	switch generator.(type) {
+	case *fileServer, *dirServer:
+		return true
+	default:
+		return false
+	}
+}
+
+// MultiError aggregates the errors returned by Check
+type MultiError []error
+
+func (errs MultiError) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, ". ")
+}
+
+// Is returns true if any of the errors match the target
+func (errs MultiError) Is(target error) bool {
+	for _, err := range errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// GeneratorInfo describes a registered generator
+type GeneratorInfo struct {
+	Path       string
+	Kind       string // "file", "dir" or "server"
+	TypeName   string // Go type of the generator
+	CacheState string // "warm" if the generator's output is cached, otherwise "cold"
+}
+
+// ListGenerators returns the registered generators sorted by path
+func (f *FileSystem) ListGenerators() (infos []GeneratorInfo) {
+	f.node.Walk(func(node *treefs.Node) bool {
+		generator, ok := node.Generator()
+		if !ok {
+			return true
+		}
+		info := GeneratorInfo{
+			Path:       node.Path(),
+			Kind:       "file",
+			TypeName:   reflect.TypeOf(generator).String(),
+			CacheState: "cold",
+		}
+		switch generator.(type) {
+		case *fileServer, *dirServer:
+			info.Kind = "server"
+		default:
+			if node.Mode().IsDir() {
+				info.Kind = "dir"
+			}
+		}
+		if f.cacheWarm(info.Kind, node.Path()) {
+			info.CacheState = "warm"
+		}
+		infos = append(infos, info)
+		return true
+	})
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Path < infos[j].Path
+	})
+	return infos
+}
+
+// cacheWarm returns true if the generator at path has cached output. File
+// servers cache the files they serve rather than the directory itself.
+func (f *FileSystem) cacheWarm(kind, path string) bool {
+	if f.cache.Has(path) {
+		return true
+	} else if kind != "server" {
+		return false
+	}
+	warm := false
+	f.cache.Range(func(cached string, _ virtual.Entry) bool {
+		warm = strings.HasPrefix(cached, path+"/")
+		return !warm
+	})
+	return warm
+}
+
+// Debug writes the internal state of the filesystem to w for diagnostics
+func (f *FileSystem) Debug(w io.Writer) error {
+	b := new(bytes.Buffer)
+	b.WriteString("generators:\n")
+	f.node.Walk(func(node *treefs.Node) bool {
+		if generator, ok := node.Generator(); ok {
+			fmt.Fprintf(b, "  %s mode=%s type=%s\n", node.Path(), node.Mode(), reflect.TypeOf(generator))
+		}
+		return true
+	})
+	b.WriteString("cache:\n")
+	var cached []string
+	sizes := map[string]int{}
+	f.cache.Range(func(path string, entry virtual.Entry) bool {
+		cached = append(cached, path)
+		if file, ok := entry.(*virtual.File); ok {
+			sizes[path] = len(file.Data)
+		}
+		return true
+	})
+	sort.Strings(cached)
+	for _, path := range cached {
+		fmt.Fprintf(b, "  %s size=%d\n", path, sizes[path])
+	}
+	b.WriteString("links:\n")
+	var froms []string
+	lists := map[string]*linkmap.List{}
+	f.lmap.Range(func(path string, list *linkmap.List) bool {
+		froms = append(froms, path)
+		lists[path] = list
+		return true
+	})
+	sort.Strings(froms)
+	for _, from := range froms {
+		fmt.Fprintf(b, "  %s selectors=%d\n", from, lists[from].Selectors())
+		for _, to := range lists[from].Paths() {
+			fmt.Fprintf(b, "    -> %s\n", to)
+		}
+	}
+	fmt.Fprintf(b, "cache bytes: %d\n", f.CacheBytes())
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func (f *FileSystem) Close() error {
+	return f.closer.Close()
+}
+
+type fileGenerator struct {
+	fsys  *FileSystem
+	fn    func(fsys FS, file *File) error
+	node  *treefs.Node
+	group singleflight.Group
+}
+
+var _ treefs.ContextGenerator = (*fileGenerator)(nil)
+
+func (g *fileGenerator) Generate(target string) (fs.File, error) {
+	return g.GenerateContext(context.Background(), target)
+}
+
+func (g *fileGenerator) GenerateContext(ctx context.Context, target string) (fs.File, error) {
+	if entry, ok := g.fsys.cacheGet(target); ok {
+		return virtual.New(entry), nil
+	}
+	// Deduplicate concurrent calls for the same target. Each caller gets its
+	// own file from the shared entry because files have a read offset.
+	entry, err, _ := g.group.Do(target, func() (interface{}, error) {
+		return g.generate(ctx, target)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return virtual.New(entry.(*virtual.File)), nil
+}
+
+func (g *fileGenerator) generate(ctx context.Context, target string) (*virtual.File, error) {
+	if entry, ok := g.fsys.cache.Get(target); ok {
+		if vfile, ok := entry.(*virtual.File); ok {
+			return vfile, nil
+		}
+	}
+	fctx := &fileSystem{ctx, g.fsys, g.fsys.lmap.Scope(target), g.node.Path()}
+	if err := g.fsys.generateDependencies(fctx, target); err != nil {
+		return nil, err
+	}
+	file := &File{nil, g.node, target}
+	g.fsys.log.Debug("budfs: running file generator function", "target", target)
+	g.fsys.generated(target)
+	if err := g.fn(fctx, file); err != nil {
+		return nil, err
+	}
+	vfile := &virtual.File{
+		Path:    g.node.Path(),
+		Mode:    g.node.Mode(),
+		Data:    file.Data,
+		ModTime: g.fsys.root.clock(),
+	}
+	g.fsys.cache.Set(target, vfile)
+	return vfile, nil
+}
+
+// Freeze the filesystem, so registering a generator panics. Call Freeze after
+// the generators have been registered to catch generators that are registered
+// while the filesystem is in use. Directory generators can still register
+// generators within their directory.
+func (f *FileSystem) Freeze() {
+	atomic.StoreInt32(&f.root.frozen, 1)
+}
+
+// Frozen returns true if the filesystem has been frozen
+func (f *FileSystem) Frozen() bool {
+	return atomic.LoadInt32(&f.root.frozen) == 1
+}
+
+// mustNotBeFrozen panics if a generator is registered after Freeze
+func (f *FileSystem) mustNotBeFrozen(path string) {
+	if f.Frozen() {
+		panic(fmt.Sprintf("budfs: unable to register a generator for %q because the filesystem is frozen", f.path(path)))
+	}
+}
+
+func (f *FileSystem) GenerateFile(path string, fn func(fsys FS, file *File) error) {
+	f.mustNotBeFrozen(path)
+	fileg := &fileGenerator{fsys: f.root, fn: fn}
+	fileg.node = f.node.FileGenerator(f.path(path), fileg)
+}
+
+func (f *FileSystem) FileGenerator(path string, generator FileGenerator) {
+	f.GenerateFile(path, generator.GenerateFile)
+}
+
+// ReplaceFile swaps the generator of the file at path for fn. The previous
+// generator's cached output is invalidated, along with the files that were
+// generated from it. Returns an error if there's no file generator at path.
+func (f *FileSystem) ReplaceFile(path string, fn func(fsys FS, file *File) error) error {
+	f.mustNotBeFrozen(path)
+	full := f.path(path)
+	node, ok := f.node.Find(full)
+	if !ok || node.Mode().IsDir() {
+		return fmt.Errorf("budfs: unable to replace %q. %w", full, fs.ErrNotExist)
+	}
+	fileg := &fileGenerator{fsys: f.root, fn: fn, node: node}
+	if err := f.node.Replace(full, fileg); err != nil {
+		return fmt.Errorf("budfs: unable to replace %q. %w", full, err)
+	}
+	f.Change(path)
+	// Forget the files the previous generator read
+	f.lmap.Delete(full)
+	return nil
+}
+
+// fileSetGenerator generates multiple files with a single call
+type fileSetGenerator struct {
+	fsys  *FileSystem
+	fn    func(fsys FS) (map[string][]byte, error)
+	paths []string // Paths as passed to fn
+	group singleflight.Group
+
+	mu    sync.RWMutex
+	fulls map[string]string // Path to full path
+}
+
+var _ treefs.ContextGenerator = (*fileSetGenerator)(nil)
+
+func (g *fileSetGenerator) Generate(target string) (fs.File, error) {
+	return g.GenerateContext(context.Background(), target)
+}
+
+func (g *fileSetGenerator) GenerateContext(ctx context.Context, target string) (fs.File, error) {
+	if !g.cached() {
+		// Deduplicate concurrent calls across all the paths in the set
+		if _, err, _ := g.group.Do("set", func() (interface{}, error) {
+			return nil, g.generate(ctx, target)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	entry, ok := g.fsys.cache.Get(target)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: target, Err: fs.ErrNotExist}
+	}
+	return virtual.New(entry), nil
+}
+
+// cached returns true if every file in the set is cached
+func (g *fileSetGenerator) cached() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	for _, full := range g.fulls {
+		if !g.fsys.cache.Has(full) {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *fileSetGenerator) generate(ctx context.Context, target string) error {
+	if g.cached() {
+		return nil
+	}
+	// Link the set to the first path, since changes evict that path and
+	// evicting any path regenerates the set
+	first := g.full(g.paths[0])
+	fctx := &fileSystem{ctx, g.fsys, g.fsys.lmap.Scope(first), first}
+	if err := g.fsys.generateDependencies(fctx, target); err != nil {
+		return err
+	}
+	g.fsys.log.Debug("budfs: running file set generator function", "target", target)
+	g.fsys.generated(target)
+	files, err := g.fn(fctx)
+	if err != nil {
+		return err
+	}
+	for _, path := range g.paths {
+		if _, ok := files[path]; !ok {
+			return fmt.Errorf("budfs: file set generator didn't return %q", path)
+		}
+	}
+	if len(files) != len(g.paths) {
+		return fmt.Errorf("budfs: file set generator returned %d files, but expected %d", len(files), len(g.paths))
+	}
+	now := g.fsys.root.clock()
+	for _, path := range g.paths {
+		full := g.full(path)
+		g.fsys.cache.Set(full, &virtual.File{
+			Path:    full,
+			Data:    files[path],
+			ModTime: now,
+		})
+	}
+	return nil
+}
+
+// full returns the full path of a path in the set
+func (g *fileSetGenerator) full(path string) string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.fulls[path]
+}
+
+func (g *fileSetGenerator) rebase(from, to string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for path, full := range g.fulls {
+		g.fulls[path] = rebasePath(full, from, to)
+	}
+}
+
+// GenerateFileSet registers a generator that produces all the paths with a
+// single call to fn. Opening any of the paths calls fn once and caches every
+// file. fn must return the data for each path, keyed by path. When any of the
+// files are evicted, the whole set is generated again.
+func (f *FileSystem) GenerateFileSet(paths []string, fn func(fsys FS) (map[string][]byte, error)) {
+	f.mustNotBeFrozen(strings.Join(paths, ", "))
+	if len(paths) == 0 {
+		return
+	}
+	setg := &fileSetGenerator{fsys: f.root, fn: fn, paths: paths, fulls: map[string]string{}}
+	for _, path := range paths {
+		full := f.path(path)
+		setg.fulls[path] = full
+		f.node.FileGenerator(full, setg)
+	}
+}
+
+// lazyGenerator suppresses the file generator until it's triggered
+type lazyGenerator struct {
+	*fileGenerator
+	triggered int32
+}
+
+var _ treefs.ContextGenerator = (*lazyGenerator)(nil)
+
+func (g *lazyGenerator) Generate(target string) (fs.File, error) {
+	return g.GenerateContext(context.Background(), target)
+}
+
+func (g *lazyGenerator) GenerateContext(ctx context.Context, target string) (fs.File, error) {
+	if atomic.LoadInt32(&g.triggered) == 0 {
+		return nil, &fs.PathError{Op: "open", Path: target, Err: fs.ErrNotExist}
+	}
+	return g.fileGenerator.GenerateContext(ctx, target)
+}
+
+// GenerateLazy is like GenerateFile, but the file doesn't exist until Trigger
+// is called. Use this for generators that should only run after an external
+// event, such as after the source files have been indexed.
+func (f *FileSystem) GenerateLazy(path string, fn func(fsys FS, file *File) error) {
+	f.mustNotBeFrozen(path)
+	lazyg := &lazyGenerator{fileGenerator: &fileGenerator{fsys: f.root, fn: fn}}
+	lazyg.node = f.node.FileGenerator(f.path(path), lazyg)
+	lazyg.node.SetVisible(false)
+}
+
+// Trigger enables the lazy generator registered at path and generates the
+// file, returning the generator's error.
+func (f *FileSystem) Trigger(path string) error {
+	node, ok := f.node.Find(f.path(path))
+	if !ok {
+		return fmt.Errorf("budfs: unable to trigger %q. %w", path, fs.ErrNotExist)
+	}
+	generator, _ := node.Generator()
+	lazyg, ok := generator.(*lazyGenerator)
+	if !ok {
+		return fmt.Errorf("budfs: unable to trigger %q. %q isn't a lazy generator", path, path)
+	}
+	atomic.StoreInt32(&lazyg.triggered, 1)
+	node.SetVisible(true)
+	file, err := f.Open(path)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// GenerateFileTemplate generates a file by executing a template with the
+// result of dataFn. The template is parsed at registration time and panics if
+// it's invalid.
+func (f *FileSystem) GenerateFileTemplate(path, tmplStr string, dataFn func(fsys FS) (interface{}, error)) {
+	tmpl := template.Must(template.New(path).Parse(tmplStr))
+	f.GenerateFile(path, templateFile(tmpl, dataFn))
+}
+
+// GenerateBinaryFile generates a file by writing to w. Use this for binary
+// output like images or compiled assets.
+func (f *FileSystem) GenerateBinaryFile(path string, fn func(fsys FS, w io.Writer) error) {
+	f.GenerateFile(path, func(fsys FS, file *File) error {
+		buf := new(bytes.Buffer)
+		if err := fn(fsys, buf); err != nil {
+			return err
+		}
+		file.Data = buf.Bytes()
+		return nil
+	})
+}
+
+// GenerateGoFile generates a Go file from the syntax tree returned by fn. The
+// tree is formatted with go/format, so the output is always valid Go syntax.
+func (f *FileSystem) GenerateGoFile(path string, fn func(fsys FS) (*ast.File, error)) {
+	f.GenerateFile(path, func(fsys FS, file *File) error {
+		node, err := fn(fsys)
+		if err != nil {
+			return err
+		}
+		buf := new(bytes.Buffer)
+		if err := format.Node(buf, token.NewFileSet(), node); err != nil {
+			return fmt.Errorf("budfs: unable to format %q. %w", file.Target(), err)
+		}
+		file.Data = buf.Bytes()
+		return nil
+	})
+}
+
+// GenerateFileStream generates a file from a source file that's streamed in,
+// rather than loaded into memory. The source is opened at path without prefix,
+// so generating "bud/public/logo.png" with the prefix "bud" reads
+// "public/logo.png". Use this for asset pipelines like image optimizers.
+func (f *FileSystem) GenerateFileStream(path, prefix string, fn func(fsys FS, in io.ReadSeeker, file *File) error) {
+	if !fs.ValidPath(prefix) || prefix == "." || !strings.HasPrefix(path, prefix+"/") {
+		panic(fmt.Sprintf("budfs: unable to stream %q because it's not within %q", path, prefix))
+	}
+	source := f.path(strings.TrimPrefix(path, prefix+"/"))
+	f.GenerateFile(path, func(fsys FS, file *File) error {
+		in, err := fsys.Open(source)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		seeker, ok := in.(io.ReadSeeker)
+		if !ok {
+			// Buffer sources that aren't seekable
+			data, err := io.ReadAll(in)
+			if err != nil {
+				return err
+			}
+			seeker = bytes.NewReader(data)
+		}
+		return fn(fsys, seeker, file)
+	})
+}
+
+// templateFile generates a file by executing tmpl with the result of dataFn
+func templateFile(tmpl *template.Template, dataFn func(fsys FS) (interface{}, error)) func(fsys FS, file *File) error {
+	return func(fsys FS, file *File) error {
+		data, err := dataFn(fsys)
+		if err != nil {
+			return err
+		}
+		buf := new(bytes.Buffer)
+		if err := tmpl.Execute(buf, data); err != nil {
+			return err
+		}
+		file.Data = buf.Bytes()
+		return nil
+	}
+}
+
+type dirGenerator struct {
+	fsys *FileSystem
+	fn   func(fsys FS, dir *Dir) error
+	node *treefs.Node
+
+	mu      sync.Mutex
+	emitted map[string]*virtual.File // Files emitted by the last run, keyed by path
+}
+
+var _ treefs.ContextGenerator = (*dirGenerator)(nil)
+
+func (g *dirGenerator) Generate(target string) (fs.File, error) {
+	return g.GenerateContext(context.Background(), target)
+}
+
+func (g *dirGenerator) GenerateContext(ctx context.Context, target string) (fs.File, error) {
+	if _, ok := g.fsys.cacheGet(g.node.Path()); ok && g.emittedCached() {
+		return g.open(ctx, target)
+	}
+	g.resetEmitted()
+	fctx := &fileSystem{ctx, g.fsys, g.fsys.lmap.Scope(target), g.node.Path()}
+	if err := g.fsys.generateDependencies(fctx, g.node.Path()); err != nil {
+		return nil, err
+	}
+	dir := &Dir{g.fsys, g.node, target, g}
+	g.fsys.log.Debug("budfs: running dir generator function", "path", g.node.Path(), "target", target)
+	g.fsys.generated(g.node.Path())
+	if err := g.fn(fctx, dir); err != nil {
+		return nil, err
+	}
+	g.fsys.cache.Set(g.node.Path(), &virtual.Dir{
+		Path:    g.node.Path(),
+		Mode:    g.node.Mode(),
+		Entries: g.node.Entries(),
+	})
+	return g.open(ctx, target)
+}
+
+// open the target, including the files emitted by the generator
+func (g *dirGenerator) open(ctx context.Context, target string) (fs.File, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.emitted) == 0 {
+		return g.node.OpenContext(ctx, target)
+	}
+	if target != g.node.Path() {
+		if vfile, ok := g.emitted[target]; ok {
+			return virtual.New(vfile), nil
+		}
+		return g.node.OpenContext(ctx, target)
+	}
+	entries := g.node.Entries()
+	for _, vfile := range g.emitted {
+		entries = append(entries, vfile)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return virtual.New(&virtual.Dir{
+		Path:    g.node.Path(),
+		Mode:    g.node.Mode(),
+		Entries: entries,
+	}), nil
+}
+
+func (g *dirGenerator) emit(path string, data []byte) {
+	vfile := &virtual.File{
+		Path: path,
+		Mode: fs.FileMode(0),
+		Data: data,
+	}
+	g.mu.Lock()
+	if g.emitted == nil {
+		g.emitted = map[string]*virtual.File{}
+	}
+	g.emitted[path] = vfile
+	g.mu.Unlock()
+	g.fsys.cache.Set(path, vfile)
+}
+
+// emittedCached returns false if any of the emitted files have been evicted
+// from the cache, such as after a change
+func (g *dirGenerator) emittedCached() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for path := range g.emitted {
+		if !g.fsys.cache.Has(path) {
+			return false
+		}
+	}
+	return true
+}
+
+// rebase the emitted files after the directory moved
+func (g *dirGenerator) rebase(from, to string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.emitted) == 0 {
+		return
+	}
+	emitted := make(map[string]*virtual.File, len(g.emitted))
+	for path, vfile := range g.emitted {
+		rebased := rebasePath(path, from, to)
+		emitted[rebased] = &virtual.File{
+			Path: rebased,
+			Mode: vfile.Mode,
+			Data: vfile.Data,
+		}
+	}
+	g.emitted = emitted
+}
+
+// resetEmitted removes the files emitted by the previous run
+func (g *dirGenerator) resetEmitted() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for path := range g.emitted {
+		g.fsys.cache.Delete(path)
+	}
+	g.emitted = nil
+}
+
+func (f *FileSystem) GenerateDir(path string, fn func(fsys FS, dir *Dir) error) {
+	f.mustNotBeFrozen(path)
+	dirg := &dirGenerator{fsys: f.root, fn: fn}
+	dirg.node = f.node.DirGenerator(f.path(path), dirg)
+}
+
+// GenerateDirEager is like GenerateDir, but fn is called right away to register
+// the directory's children, so ReadDir lists them before anything is opened.
+// Returns fn's error, so failures surface at registration. The directory stays
+// registered, so opening it later calls fn again.
+func (f *FileSystem) GenerateDirEager(path string, fn func(fsys FS, dir *Dir) error) error {
+	f.mustNotBeFrozen(path)
+	dirg := &dirGenerator{fsys: f.root, fn: fn}
+	dirg.node = f.node.DirGenerator(f.path(path), dirg)
+	file, err := dirg.Generate(dirg.node.Path())
+	if err != nil {
+		return fmt.Errorf("budfs: unable to eagerly generate %q. %w", dirg.node.Path(), err)
+	}
+	return file.Close()
+}
+
+func (f *FileSystem) DirGenerator(path string, generator DirGenerator) {
+	f.GenerateDir(path, generator.GenerateDir)
+}
+
+// GenerateDirFromMap generates a directory containing a known set of files.
+// Each generator is registered under its name in sorted order when the
+// directory is generated.
+func (f *FileSystem) GenerateDirFromMap(path string, files map[string]FileGenerator) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	f.GenerateDir(path, func(fsys FS, dir *Dir) error {
+		for _, name := range names {
+			dir.FileGenerator(name, files[name])
+		}
+		return nil
+	})
+}
+
+type dirOnceGenerator struct {
+	fsys *FileSystem
+	fn   func(fsys FS, dir *Dir) error
+	node *treefs.Node
+	once sync.Once
+	err  error
+}
+
+var _ treefs.ContextGenerator = (*dirOnceGenerator)(nil)
+
+func (g *dirOnceGenerator) Generate(target string) (fs.File, error) {
+	return g.GenerateContext(context.Background(), target)
+}
+
+func (g *dirOnceGenerator) GenerateContext(ctx context.Context, target string) (fs.File, error) {
+	g.once.Do(func() {
+		fctx := &fileSystem{ctx, g.fsys, g.fsys.lmap.Scope(target), g.node.Path()}
+		dir := &Dir{g.fsys, g.node, target, nil}
+		g.fsys.log.Debug("budfs: running dir setup function", "path", g.node.Path(), "target", target)
+		g.fsys.generated(g.node.Path())
+		g.err = g.fn(fctx, dir)
+	})
+	if g.err != nil {
+		return nil, g.err
+	}
+	return g.node.OpenContext(ctx, target)
+}
+
+// GenerateDirOnce is like GenerateDir, but setup is guaranteed to run exactly
+// once, regardless of caching. Use this when setup registers generators.
+func (f *FileSystem) GenerateDirOnce(path string, setup func(fsys FS, dir *Dir) error) {
+	f.mustNotBeFrozen(path)
+	dirg := &dirOnceGenerator{fsys: f.root, fn: setup}
+	dirg.node = f.node.DirGenerator(f.path(path), dirg)
+}
+
+type fileServer struct {
+	fsys *FileSystem
+	fn   func(ctx context.Context, fsys FS, file *File) error
+	node *treefs.Node
+}
+
+var _ treefs.ContextGenerator = (*fileServer)(nil)
+
+func (g *fileServer) Generate(target string) (fs.File, error) {
+	return g.GenerateContext(context.Background(), target)
+}
+
+func (g *fileServer) GenerateContext(ctx context.Context, target string) (fs.File, error) {
+	if entry, ok := g.fsys.cacheGet(target); ok {
+		return virtual.New(entry), nil
+	}
+	rel := relativePath(g.node.Path(), target)
+	if rel == "." {
+		return nil, &fs.PathError{
+			Op:   "open",
+			Path: g.node.Path(),
+			Err:  fs.ErrInvalid,
+		}
+	}
+	fctx := &fileSystem{ctx, g.fsys, g.fsys.lmap.Scope(target), g.node.Path()}
+	if err := g.fsys.generateDependencies(fctx, target); err != nil {
+		return nil, err
+	}
+	// File differs slightly than others because g.node.Path() is the directory
+	// path, but we want the target path for serving files.
+	file := &File{nil, g.node, target}
+	g.fsys.log.Debug("budfs: running file server function", "path", g.node.Path(), "target", target)
+	g.fsys.generated(target)
+	if err := g.fn(ctx, fctx, file); err != nil {
+		return nil, err
+	}
+	vfile := &virtual.File{
+		Path:    target,
+		Mode:    fs.FileMode(0),
+		Data:    file.Data,
+		ModTime: g.fsys.root.clock(),
+	}
+	g.fsys.cache.Set(target, vfile)
+	return virtual.New(vfile), nil
+}
+
+func (f *FileSystem) ServeFile(dir string, fn func(fsys FS, file *File) error) {
+	f.ServeFileContext(dir, func(_ context.Context, fsys FS, file *File) error {
+		return fn(fsys, file)
+	})
+}
+
+// ServeFileContext is like ServeFile, but fn receives the context passed to
+// OpenContext for per-request cancellation, tracing and deadlines.
+func (f *FileSystem) ServeFileContext(dir string, fn func(ctx context.Context, fsys FS, file *File) error) {
+	f.mustNotBeFrozen(dir)
+	fileg := &fileServer{f.root, fn, nil}
+	fileg.node = f.node.DirGenerator(f.path(dir), fileg)
+}
+
+func (f *FileSystem) FileServer(dir string, generator FileGenerator) {
+	f.ServeFile(dir, generator.GenerateFile)
+}
+
+type dirServer struct {
+	fsys *FileSystem
+	fn   func(fsys FS, dir *Dir) error
+	node *treefs.Node
+	mu   sync.Mutex
+}
+
+var _ treefs.ContextGenerator = (*dirServer)(nil)
+
+func (g *dirServer) Generate(target string) (fs.File, error) {
+	return g.GenerateContext(context.Background(), target)
+}
+
+func (g *dirServer) GenerateContext(ctx context.Context, target string) (fs.File, error) {
+	entry, err := g.populate(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	if target == g.node.Path() {
+		return virtual.New(entry), nil
+	}
+	return g.node.OpenContext(ctx, target)
+}
+
+// populate calls fn to fill in the directory, unless the listing is cached
+func (g *dirServer) populate(ctx context.Context, target string) (virtual.Entry, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if entry, ok := g.fsys.cacheGet(g.node.Path()); ok {
+		return entry, nil
+	}
+	fctx := &fileSystem{ctx, g.fsys, g.fsys.lmap.Scope(g.node.Path()), g.node.Path()}
+	if err := g.fsys.generateDependencies(fctx, g.node.Path()); err != nil {
+		return nil, err
+	}
+	dir := &Dir{g.fsys, g.node, target, nil}
+	g.fsys.log.Debug("budfs: running dir server function", "path", g.node.Path(), "target", target)
+	g.fsys.generated(g.node.Path())
+	if err := g.fn(fctx, dir); err != nil {
+		return nil, err
+	}
+	entry := &virtual.Dir{
+		Path:    g.node.Path(),
+		Mode:    g.node.Mode(),
+		Entries: g.node.Entries(),
+	}
+	g.fsys.cache.Set(g.node.Path(), entry)
+	return entry, nil
+}
+
+// ServeDir is the directory listing analogue of ServeFile. fn is called the
+// first time the directory is listed to register its entries. The listing is
+// cached until the directory changes. Opening a path within the directory
+// before it's been listed also calls fn.
+func (f *FileSystem) ServeDir(dir string, fn func(fsys FS, dir *Dir) error) {
+	f.mustNotBeFrozen(dir)
+	dirs := &dirServer{fsys: f.root, fn: fn}
+	dirs.node = f.node.DirGenerator(f.path(dir), dirs)
+}
+
+type aliasGenerator struct {
+	fsys *FileSystem
+	mu   sync.RWMutex
+	to   string
+}
+
+var _ treefs.ContextGenerator = (*aliasGenerator)(nil)
+
+func (g *aliasGenerator) Generate(target string) (fs.File, error) {
+	return g.GenerateContext(context.Background(), target)
+}
+
+func (g *aliasGenerator) GenerateContext(ctx context.Context, target string) (fs.File, error) {
+	return g.fsys.openIntercepted(ctx, g.target())
+}
+
+func (g *aliasGenerator) target() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.to
+}
+
+// rebase the alias when the file it points to moved
+func (g *aliasGenerator) rebase(from, to string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.to = rebasePath(g.to, from, to)
+}
+
+// Alias redirects opening from to opening to. The alias doesn't have its own
+// cache entry, it returns whatever opening to returns.
+func (f *FileSystem) Alias(from, to string) {
+	f.mustNotBeFrozen(from)
+	aliasg := &aliasGenerator{fsys: f.root, to: f.path(to)}
+	f.node.FileGenerator(f.path(from), aliasg)
+}
+
+// Aliases returns the registered aliases, mapping from to to
+func (f *FileSystem) Aliases() map[string]string {
+	aliases := map[string]string{}
+	f.node.Walk(func(node *treefs.Node) bool {
+		generator, ok := node.Generator()
+		if !ok {
+			return true
+		}
+		if aliasg, ok := generator.(*aliasGenerator); ok {
+			aliases[node.Path()] = aliasg.target()
+		}
+		return true
+	})
+	return aliases
+}
+
+// FileCount returns the number of registered file generators, including those
+// that haven't generated yet
+func (f *FileSystem) FileCount() int {
+	return f.countGenerators(false)
+}
+
+// DirCount returns the number of registered directory generators, including
+// those that haven't generated yet
+func (f *FileSystem) DirCount() int {
+	return f.countGenerators(true)
+}
+
+func (f *FileSystem) countGenerators(dir bool) (count int) {
+	f.node.Walk(func(node *treefs.Node) bool {
+		if _, ok := node.Generator(); ok && node.Mode().IsDir() == dir {
+			count++
+		}
+		return true
+	})
+	return count
+}
+
+// CacheKey returns the key used to cache the generated path. File generators
+// and file servers are cached by the target path, while directory generators
+// are cached by the directory's path. Returns false if the path isn't served by
+// a cached generator.
+func (f *FileSystem) CacheKey(path string) (key string, found bool) {
+	key, found = f.root.cacheKey(f.path(path))
+	if !found {
+		return "", false
+	}
+	// Cache key may be outside of the namespace
+	return f.relative(key)
+}
+
+func (f *FileSystem) cacheKey(target string) (string, bool) {
+	if !fs.ValidPath(target) {
+		return "", false
+	}
+	node, _, found := f.node.FindByPrefix(target)
+	if !found {
+		return "", false
+	}
+	generator, ok := node.Generator()
+	if !ok {
+		return "", false
+	}
+	switch g := generator.(type) {
+	case *fileGenerator, *lazyGenerator, *fileSetGenerator:
+		if node.Path() != target {
+			return "", false
+		}
+		return target, true
+	case *fileServer:
+		if node.Path() == target {
+			return "", false
+		}
+		return target, true
+	case *dirGenerator:
+		return node.Path(), true
+	case *dirServer:
+		if node.Path() != target {
+			return "", false
+		}
+		return target, true
+	case *aliasGenerator:
+		return f.cacheKey(g.to)
+	default:
+		return "", false
+	}
+}
+
+// Sync the overlay to the filesystem
+func (f *FileSystem) Sync(writable virtual.FS, to string) error {
+	// Temporarily replace the underlying fs.FS with a cached fs.FS
+	cache := vcache.New()
+	root := f.root
+	fsys := root.fsys
+	root.fsys = vcache.Wrap(cache, fsys, f.log)
+	err := dsync.To(f, writable, to)
+	root.fsys = fsys
+	return err
+}
+
+// Change updates the cache
+func (f *FileSystem) Change(paths ...string) {
+	if f.prefix != "" {
+		prefixed := make([]string, len(paths))
+		for i, path := range paths {
+			prefixed[i] = f.path(path)
+		}
+		paths = prefixed
+	}
+	// Track the path that caused each path to be invalidated
+	causes := make([]string, len(paths))
+	copy(causes, paths)
+	var events []ChangeEvent
+	for _, path := range paths {
+		f.tlog.record("change", path)
+	}
+	for i := 0; i < len(paths); i++ {
+		path := paths[i]
+		if f.cache.Has(path) {
+			f.log.Debug("budfs: cache", "delete", path)
+			f.cache.Delete(path)
+			events = append(events, ChangeEvent{Path: path, Cause: causes[i]})
+		}
+		f.lmap.Range(func(genPath string, fns *linkmap.List) bool {
+			if f.cache.Has(genPath) && fns.Check(path) {
+				paths = append(paths, genPath)
+				causes = append(causes, path)
+			}
+			return true
+		})
+	}
+	if len(events) > 0 {
+		f.root.subs.publish(events)
+	}
+	if f.root.notifier != nil && len(events) > 0 {
+		if err := f.root.notifier.Notify(events); err != nil {
+			f.log.Error("budfs: unable to notify", "error", err)
+		}
+	}
+}
+
+// Subscribe registers fn to be called after each Change with the paths that
+// were removed from the cache. fn is called synchronously, so it should return
+// quickly. Calling cancel unsubscribes fn.
+func (f *FileSystem) Subscribe(fn func(invalidatedPaths []string)) (cancel func()) {
+	return f.root.subs.add(fn)
+}
+
+type subscribers struct {
+	mu   sync.Mutex
+	next int
+	fns  map[int]func([]string)
+}
+
+func (s *subscribers) add(fn func([]string)) (cancel func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.next
+	s.next++
+	s.fns[id] = fn
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.fns, id)
+	}
+}
+
+// publish calls the subscribers in the order they subscribed
+func (s *subscribers) publish(events []ChangeEvent) {
+	s.mu.Lock()
+	ids := make([]int, 0, len(s.fns))
+	for id := range s.fns {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	fns := make([]func([]string), len(ids))
+	for i, id := range ids {
+		fns[i] = s.fns[id]
+	}
+	s.mu.Unlock()
+	if len(fns) == 0 {
+		return
+	}
+	paths := make([]string, len(events))
+	for i, event := range events {
+		paths[i] = event.Path
+	}
+	// Call the subscribers outside the lock, so they can unsubscribe
+	for _, fn := range fns {
+		fn(paths)
+	}
+}
+
+// OverrideFile serves data at path without running a generator, until the
+// override is cleared with ClearOverride. Overrides aren't invalidated by
+// Change. Use OverrideFile in tests to stub out the output of expensive
+// generators.
+func (f *FileSystem) OverrideFile(path string, data []byte) {
+	full := f.path(path)
+	vfile := &virtual.File{
+		Path: full,
+		Data: data,
+	}
+	f.root.overrides.set(full, vfile)
+	f.cache.Set(full, vfile)
+}
+
+// ClearOverride removes the override at path, so the path is generated again
+func (f *FileSystem) ClearOverride(path string) {
+	full := f.path(path)
+	if f.root.overrides.delete(full) {
+		f.cache.Delete(full)
+	}
+}
+
+type overrides struct {
+	mu    sync.RWMutex
+	files map[string]*virtual.File
+}
+
+func (o *overrides) get(path string) (*virtual.File, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	vfile, ok := o.files[path]
+	return vfile, ok
+}
+
+func (o *overrides) set(path string, vfile *virtual.File) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.files[path] = vfile
+}
+
+// delete the override, returning true if it existed
+func (o *overrides) delete(path string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, ok := o.files[path]
+	delete(o.files, path)
+	return ok
+}
+
+// AsyncChange is like Change, but the paths are invalidated in the background.
+// The returned channel is closed once the paths have been invalidated. Changes
+// that arrive while a change is running are coalesced into a single Change.
+func (f *FileSystem) AsyncChange(paths ...string) <-chan struct{} {
+	done := make(chan struct{})
+	a := f.root.async
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, path := range paths {
+		a.paths = append(a.paths, f.path(path))
+	}
+	a.waiters = append(a.waiters, done)
+	if !a.running {
+		a.running = true
+		go f.root.drainChanges()
+	}
+	return done
+}
+
+// asyncChange holds the changes waiting to be applied by AsyncChange
+type asyncChange struct {
+	mu      sync.Mutex
+	running bool
+	paths   []string
+	waiters []chan struct{}
+}
+
+// drainChanges applies the pending changes until there are none left
+func (f *FileSystem) drainChanges() {
+	a := f.async
+	for {
+		a.mu.Lock()
+		if len(a.waiters) == 0 {
+			a.running = false
+			a.mu.Unlock()
+			return
+		}
+		paths, waiters := a.paths, a.waiters
+		a.paths, a.waiters = nil, nil
+		a.mu.Unlock()
+		f.Change(dedupe(paths)...)
+		for _, done := range waiters {
+			close(done)
+		}
+	}
+}
+
+// dedupe removes duplicate paths, keeping the first occurrence
+func dedupe(paths []string) []string {
+	seen := make(map[string]bool, len(paths))
+	unique := paths[:0]
+	for _, path := range paths {
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		unique = append(unique, path)
+	}
+	return unique
+}
+
+// ChangeEvent is a path that was invalidated by a change
+type ChangeEvent struct {
+	Path  string // Path that was evicted from the cache
+	Cause string // Path that changed, which is Path for direct changes
+}
+
+// Notifier is notified of the paths that were invalidated by a change. Wrap a
+// watcher or a live-reload server with a Notifier to react to changes.
+type Notifier interface {
+	Notify(events []ChangeEvent) error
+}
+
+// SetNotifier sets n to be notified after each Change that invalidates cached
+// paths. Errors from n are logged. SetNotifier should be called before the
+// filesystem is used.
+func (f *FileSystem) SetNotifier(n Notifier) {
+	f.root.notifier = n
+}
+
+// MarkDirty evicts the paths from the cache, so the next open reruns their
+// generators. Unlike Change, files that link to the paths aren't evicted.
+func (f *FileSystem) MarkDirty(paths ...string) {
+	for _, path := range paths {
+		path = f.path(path)
+		f.log.Debug("budfs: mark dirty", "path", path)
+		f.cache.Delete(path)
+	}
+}
+
+// ClearCacheForDir evicts every cached path within dir, including dir itself,
+// and returns the number of evicted entries. Use this when a directory is
+// renamed or a module is reloaded.
+func (f *FileSystem) ClearCacheForDir(dir string) int {
+	dir = f.path(dir)
+	var paths []string
+	f.cache.Range(func(path string, _ virtual.Entry) bool {
+		if dir == "." || path == dir || strings.HasPrefix(path, dir+"/") {
+			paths = append(paths, path)
+		}
+		return true
+	})
+	for _, path := range paths {
+		f.cache.Delete(path)
+	}
+	f.log.Debug("budfs: cleared cache", "dir", dir, "evicted", len(paths))
+	return len(paths)
+}
+
+// MovePath moves the generator at from to to, along with any generators within
+// it. Cached files and links are moved too, so the generated output follows a
+// renamed source file. Aliases that point into from are updated to point into
+// to. Returns an error if from doesn't exist, to already exists or from is a
+// file within a mounted directory.
+func (f *FileSystem) MovePath(from, to string) error {
+	if !fs.ValidPath(from) || !fs.ValidPath(to) {
+		return fmt.Errorf("budfs: unable to move %q to %q. %w", from, to, fs.ErrInvalid)
+	}
+	from, to = f.path(from), f.path(to)
+	if err := f.movable(from); err != nil {
+		return fmt.Errorf("budfs: unable to move %q to %q. %w", from, to, err)
+	}
+	if err := f.node.Move(from, to); err != nil {
+		return fmt.Errorf("budfs: unable to move %q to %q. %w", from, to, err)
+	}
+	f.rebaseGenerators(from, to)
+	var paths []string
+	f.cache.Range(func(path string, _ virtual.Entry) bool {
+		if path == from || strings.HasPrefix(path, from+"/") {
+			paths = append(paths, path)
+		}
+		return true
+	})
+	for _, path := range paths {
+		entry, ok := f.cache.Get(path)
+		f.cache.Delete(path)
+		if !ok {
+			continue
+		}
+		// Directory listings are regenerated since their entries hold paths
+		vfile, ok := entry.(*virtual.File)
+		if !ok {
+			continue
+		}
+		rebased := to + strings.TrimPrefix(path, from)
+		f.cache.Set(rebased, &virtual.File{
+			Path:    rebased,
+			Data:    vfile.Data,
+			Mode:    vfile.Mode,
+			ModTime: vfile.ModTime,
+		})
+	}
+	f.lmap.Move(from, to)
+	f.log.Debug("budfs: moved path", "from", from, "to", to)
+	return nil
+}
+
+// rebaser is implemented by generators that store paths, so the paths can be
+// rebased when the generator is moved
+type rebaser interface {
+	rebase(from, to string)
+}
+
+// rebasePath moves path to to if it's from or within from
+func rebasePath(path, from, to string) string {
+	if path == from {
+		return to
+	} else if strings.HasPrefix(path, from+"/") {
+		return to + strings.TrimPrefix(path, from)
+	}
+	return path
+}
+
+// movable returns an error if from is a file within a mount, since the other
+// mounted files would need to stay behind
+func (f *FileSystem) movable(from string) error {
+	node, ok := f.node.Find(from)
+	if !ok {
+		return nil
+	}
+	var err error
+	node.Walk(func(node *treefs.Node) bool {
+		generator, _ := node.Generator()
+		if mountg, ok := generator.(*mountGenerator); ok && err == nil {
+			mountg.mu.RLock()
+			dir := mountg.dir
+			mountg.mu.RUnlock()
+			if rebasePath(dir, from, "") == dir {
+				err = fmt.Errorf("%q is mounted within %q. %w", node.Path(), dir, fs.ErrInvalid)
+			}
+		}
+		return err == nil
+	})
+	return err
+}
+
+// rebaseGenerators rebases the paths stored within the generators, including
+// aliases elsewhere that point into the moved path
+func (f *FileSystem) rebaseGenerators(from, to string) {
+	seen := map[rebaser]bool{}
+	f.node.Walk(func(node *treefs.Node) bool {
+		generator, _ := node.Generator()
+		if rebaser, ok := generator.(rebaser); ok && !seen[rebaser] {
+			seen[rebaser] = true
+			rebaser.rebase(from, to)
+		}
+		return true
+	})
+}
+
+// Compact removes stale internal state that builds up in long-running
+// processes: empty directories left behind by moved or removed generators,
+// cached files without a generator and links that are empty or whose
+// generator no longer exists. Compact assumes the cache isn't shared with
+// another filesystem.
+func (f *FileSystem) Compact() error {
+	pruned := f.node.Prune()
+	var orphans []string
+	f.cache.Range(func(path string, _ virtual.Entry) bool {
+		if !f.restorable(path) {
+			orphans = append(orphans, path)
+		}
+		return true
+	})
+	for _, path := range orphans {
+		f.cache.Delete(path)
+	}
+	var links []string
+	f.lmap.Range(func(path string, list *linkmap.List) bool {
+		if !f.restorable(path) || (len(list.Paths()) == 0 && list.Selectors() == 0) {
+			links = append(links, path)
+		}
+		return true
+	})
+	for _, path := range links {
+		f.lmap.Delete(path)
+	}
+	f.log.Debug("budfs: compacted", "nodes", pruned, "cache", len(orphans), "links", len(links))
+	return nil
+}
+
+type fileSystem struct {
+	ctx  context.Context
+	fsys *FileSystem
+	link *linkmap.List
+	path string // Path of the generator
+}
+
+var _ FS = (*fileSystem)(nil)
+
+// Open implements fs.FS
+func (f *fileSystem) Open(name string) (fs.File, error) {
+	file, err := f.fsys.openIntercepted(f.ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	f.link.Link("open", name)
+	return file, nil
 }
 
 func (f *fileSystem) Link(to string) {
@@ -360,10 +2486,68 @@ func (f *fileSystem) Defer(fn func() error) {
 	f.fsys.closer.Closes = append(f.fsys.closer.Closes, fn)
 }
 
+// OpenWithFallback is like FileSystem.OpenWithFallback, but the name is linked
+// even when it doesn't exist, so creating the file regenerates the generator.
+func (f *fileSystem) OpenWithFallback(name string, fallbacks ...fs.FS) (fs.File, error) {
+	f.link.Link("fallback", name)
+	return openWithFallback(func(name string) (fs.File, error) {
+		return f.fsys.openIntercepted(f.ctx, name)
+	}, name, fallbacks)
+}
+
+// Require reads a file, returning a *RequiredFileError if it doesn't exist
+func (f *fileSystem) Require(path string) ([]byte, error) {
+	data, err := fs.ReadFile(f, path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, &RequiredFileError{f.path, path, err}
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// ReadAll reads the files matching pattern concurrently, keyed by path.
+// Directories that match the pattern are skipped.
+func (f *fileSystem) ReadAll(pattern string) (map[string][]byte, error) {
+	matches, err := f.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	f.link.Link("readall", matches...)
+	var mu sync.Mutex
+	files := make(map[string][]byte, len(matches))
+	err = forEach(matches, func(_ int, path string) error {
+		file, err := f.fsys.openIntercepted(f.ctx, path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		stat, err := file.Stat()
+		if err != nil {
+			return err
+		} else if stat.IsDir() {
+			return nil
+		}
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		files[path] = data
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
 // Glob implements fs.GlobFS
 func (f *fileSystem) Glob(pattern string) (matches []string, err error) {
 	// Compile the pattern into a glob matcher
-	matcher, err := glob.Compile(pattern)
+	matcher, err := glob.CompileWithFlags(pattern, globFlags)
 	if err != nil {
 		return nil, err
 	}
@@ -393,10 +2577,14 @@ func (f *fileSystem) Glob(pattern string) (matches []string, err error) {
 
 // ReadDir implements fs.ReadDirFS
 func (f *fileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
-	des, err := fs.ReadDir(f.fsys, name)
+	file, err := f.fsys.openIntercepted(f.ctx, name)
 	if err != nil {
 		return nil, err
 	}
+	des, err := f.fsys.readDir(file)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
 	f.link.Select("readdir", func(path string) bool {
 		return path == name || filepath.Dir(path) == name
 	})
@@ -413,6 +2601,10 @@ func (f *fileSystem) glob(matcher glob.Matcher, base string) (matches []string,
 		if matcher.Match(path) {
 			matches = append(matches, path)
 		}
+		// Skip directories that can't contain any matches
+		if de.IsDir() && path != base && matcher.CanSkipDir(path) {
+			return fs.SkipDir
+		}
 		return nil
 	}))
 	if err != nil {
@@ -422,6 +2614,43 @@ func (f *fileSystem) glob(matcher glob.Matcher, base string) (matches []string,
 	return matches, nil
 }
 
+// globFlags matches case-insensitively on operating systems with
+// case-insensitive filesystems by default
+var globFlags = defaultGlobFlags(runtime.GOOS)
+
+func defaultGlobFlags(goos string) glob.Flags {
+	switch goos {
+	case "darwin", "windows":
+		return glob.FlagCaseInsensitive
+	default:
+		return 0
+	}
+}
+
+// forEach calls fn concurrently for each path with a bounded number of workers,
+// returning the first error
+func forEach(paths []string, fn func(i int, path string) error) error {
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	eg := new(errgroup.Group)
+	for i, path := range paths {
+		i, path := i, path
+		sem <- struct{}{}
+		eg.Go(func() error {
+			defer func() { <-sem }()
+			return fn(i, path)
+		})
+	}
+	return eg.Wait()
+}
+
+// openContext opens the path, passing the context through if fsys supports it
+func openContext(ctx context.Context, fsys fs.FS, name string) (fs.File, error) {
+	if cfs, ok := fsys.(mergefs.ContextFS); ok {
+		return cfs.OpenContext(ctx, name)
+	}
+	return fsys.Open(name)
+}
+
 func relativePath(base, target string) string {
 	rel := strings.TrimPrefix(target, base)
 	if rel == "" {
@@ -431,3 +2660,56 @@ func relativePath(base, target string) string {
 	}
 	return rel
 }
+
+// atomicLog wraps a logger that can be swapped out after construction
+type atomicLog struct {
+	v atomic.Value
+}
+
+var _ log.Interface = (*atomicLog)(nil)
+
+// loggerValue is stored in atomicLog to ensure atomic.Value always receives
+// the same concrete type
+type loggerValue struct {
+	log.Interface
+}
+
+func newAtomicLog(log log.Interface) *atomicLog {
+	alog := new(atomicLog)
+	alog.Store(log)
+	return alog
+}
+
+func (l *atomicLog) Store(log log.Interface) {
+	l.v.Store(loggerValue{log})
+}
+
+func (l *atomicLog) Load() log.Interface {
+	return l.v.Load().(loggerValue).Interface
+}
+
+func (l *atomicLog) Debug(message string, args ...interface{}) {
+	l.Load().Debug(message, args...)
+}
+
+func (l *atomicLog) Info(message string, args ...interface{}) {
+	l.Load().Info(message, args...)
+}
+
+func (l *atomicLog) Notice(message string, args ...interface{}) {
+	l.Load().Notice(message, args...)
+}
+
+func (l *atomicLog) Warn(message string, args ...interface{}) {
+	l.Load().Warn(message, args...)
+}
+
+func (l *atomicLog) Error(message string, args ...interface{}) {
+	l.Load().Error(message, args...)
+}
+
+// WithFields falls back to appending fields so that the returned logger still
+// follows calls to SetLogger.
+func (l *atomicLog) WithFields(fields ...interface{}) log.Interface {
+	return log.WithFields(l, fields...)
+}
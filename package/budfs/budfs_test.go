@@ -1,8 +1,14 @@
 package budfs_test
 
 import (
+	"bytes"
 	"context"
+	"embed"
+	"encoding/gob"
 	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
 	"io"
 	"io/fs"
 	"net/http"
@@ -11,18 +17,26 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"testing/fstest"
+	"text/template"
 	"time"
 
 	"github.com/livebud/bud/internal/testdir"
 
 	"github.com/livebud/bud/package/gomod"
 	"github.com/livebud/bud/package/virtual"
+	"github.com/livebud/bud/package/virtual/vcache"
 
 	"github.com/livebud/bud/internal/is"
 	"github.com/livebud/bud/package/budfs"
+	"github.com/livebud/bud/package/budfs/budfstest"
+	"github.com/livebud/bud/package/budfs/internal/geninput"
+	"github.com/livebud/bud/package/log"
 	"github.com/livebud/bud/package/log/testlog"
+	"golang.org/x/sync/errgroup"
 )
 
 func TestGenerateFile(t *testing.T) {
@@ -216,6 +230,7 @@ func TestAll(t *testing.T) {
 	fsys := virtual.Map{}
 	log := testlog.New()
 	bfs := budfs.New(fsys, log)
+	bfs.SetClock(budfstest.FakeClock())
 	bfs.GenerateDir("bud/view", view())
 
 	// .
@@ -332,7 +347,7 @@ func TestAll(t *testing.T) {
 	is.NoErr(err)
 	is.Equal(fi.Name(), "index.svelte")
 	is.Equal(fi.IsDir(), false)
-	is.True(fi.ModTime().IsZero())
+	is.Equal(fi.ModTime(), budfstest.FakeTime)
 	is.Equal(fi.Mode(), fs.FileMode(0))
 	is.Equal(fi.Size(), int64(14))
 	is.Equal(fi.Sys(), nil)
@@ -375,7 +390,7 @@ func TestAll(t *testing.T) {
 	is.NoErr(err)
 	is.Equal(fi.Name(), "index.svelte")
 	is.Equal(fi.IsDir(), false)
-	is.True(fi.ModTime().IsZero())
+	is.Equal(fi.ModTime(), budfstest.FakeTime)
 	is.Equal(fi.Mode(), fs.FileMode(0))
 	is.Equal(fi.Size(), int64(14))
 	is.Equal(fi.Sys(), nil)
@@ -395,7 +410,7 @@ func TestAll(t *testing.T) {
 	is.NoErr(err)
 	is.Equal(fi.Name(), "about.svelte")
 	is.Equal(fi.IsDir(), false)
-	is.True(fi.ModTime().IsZero())
+	is.Equal(fi.ModTime(), budfstest.FakeTime)
 	is.Equal(fi.Mode(), fs.FileMode(0))
 	is.Equal(fi.Size(), int64(14))
 	is.Equal(fi.Sys(), nil)
@@ -427,7 +442,7 @@ func TestAll(t *testing.T) {
 	is.NoErr(err)
 	is.Equal(fi.Name(), "about.svelte")
 	is.Equal(fi.IsDir(), false)
-	is.True(fi.ModTime().IsZero())
+	is.Equal(fi.ModTime(), budfstest.FakeTime)
 	is.Equal(fi.Mode(), fs.FileMode(0))
 	is.Equal(fi.Size(), int64(14))
 	is.Equal(fi.Sys(), nil)
@@ -441,7 +456,7 @@ func TestAll(t *testing.T) {
 	is.Equal(stat.Name(), "index.svelte")
 	is.Equal(stat.Mode(), fs.FileMode(0))
 	is.Equal(stat.IsDir(), false)
-	is.True(stat.ModTime().IsZero())
+	is.Equal(stat.ModTime(), budfstest.FakeTime)
 	is.Equal(stat.Size(), int64(14))
 	is.Equal(stat.Sys(), nil)
 	// Stat
@@ -450,7 +465,7 @@ func TestAll(t *testing.T) {
 	is.Equal(stat.Name(), "index.svelte")
 	is.Equal(stat.Mode(), fs.FileMode(0))
 	is.Equal(stat.IsDir(), false)
-	is.True(stat.ModTime().IsZero())
+	is.Equal(stat.ModTime(), budfstest.FakeTime)
 	is.Equal(stat.Size(), int64(14))
 	is.Equal(stat.Sys(), nil)
 	// ReadFile
@@ -467,7 +482,7 @@ func TestAll(t *testing.T) {
 	is.Equal(stat.Name(), "about.svelte")
 	is.Equal(stat.Mode(), fs.FileMode(0))
 	is.Equal(stat.IsDir(), false)
-	is.True(stat.ModTime().IsZero())
+	is.Equal(stat.ModTime(), budfstest.FakeTime)
 	is.Equal(stat.Size(), int64(14))
 	is.Equal(stat.Sys(), nil)
 	// Stat
@@ -476,7 +491,7 @@ func TestAll(t *testing.T) {
 	is.Equal(stat.Name(), "about.svelte")
 	is.Equal(stat.Mode(), fs.FileMode(0))
 	is.Equal(stat.IsDir(), false)
-	is.True(stat.ModTime().IsZero())
+	is.Equal(stat.ModTime(), budfstest.FakeTime)
 	is.Equal(stat.Size(), int64(14))
 	is.Equal(stat.Sys(), nil)
 	// ReadFile
@@ -847,6 +862,7 @@ func TestEmbedOpen(t *testing.T) {
 	fsys := virtual.Map{}
 	log := testlog.New()
 	bfs := budfs.New(fsys, log)
+	bfs.SetClock(budfstest.FakeClock())
 	bfs.FileGenerator("bud/view/index.svelte", &budfs.EmbedFile{
 		Data: []byte(`<h1>index</h1>`),
 	})
@@ -862,7 +878,7 @@ func TestEmbedOpen(t *testing.T) {
 	is.Equal(string(code), `<h1>index</h1>`)
 	stat, err := fs.Stat(bfs, "bud/view/index.svelte")
 	is.NoErr(err)
-	is.Equal(stat.ModTime(), time.Time{})
+	is.Equal(stat.ModTime(), budfstest.FakeTime)
 	is.Equal(stat.Mode(), fs.FileMode(0))
 	is.Equal(stat.IsDir(), false)
 
@@ -872,7 +888,7 @@ func TestEmbedOpen(t *testing.T) {
 	is.Equal(string(code), `<h1>about</h1>`)
 	stat, err = fs.Stat(bfs, "bud/view/about/about.svelte")
 	is.NoErr(err)
-	is.Equal(stat.ModTime(), time.Time{})
+	is.Equal(stat.ModTime(), budfstest.FakeTime)
 	is.Equal(stat.Mode(), fs.FileMode(0))
 	is.Equal(stat.IsDir(), false)
 
@@ -882,7 +898,7 @@ func TestEmbedOpen(t *testing.T) {
 	is.Equal(string(code), `favicon.ico`)
 	stat, err = fs.Stat(bfs, "bud/public/favicon.ico")
 	is.NoErr(err)
-	is.Equal(stat.ModTime(), time.Time{})
+	is.Equal(stat.ModTime(), budfstest.FakeTime)
 	is.Equal(stat.Mode(), fs.FileMode(0))
 	is.Equal(stat.IsDir(), false)
 
@@ -995,6 +1011,7 @@ func TestServeFile(t *testing.T) {
 	fsys := virtual.Map{}
 	log := testlog.New()
 	bfs := budfs.New(fsys, log)
+	bfs.SetClock(budfstest.FakeClock())
 	bfs.ServeFile("duo/view", func(fsys budfs.FS, file *budfs.File) error {
 		file.Data = []byte(file.Target() + `'s data`)
 		return nil
@@ -1014,7 +1031,7 @@ func TestServeFile(t *testing.T) {
 	is.Equal(stat.Name(), "_index.svelte")
 	is.Equal(stat.Mode(), fs.FileMode(0))
 	is.Equal(stat.IsDir(), false)
-	is.True(stat.ModTime().IsZero())
+	is.Equal(stat.ModTime(), budfstest.FakeTime)
 	is.Equal(stat.Size(), int64(29))
 	is.Equal(stat.Sys(), nil)
 
@@ -1026,7 +1043,7 @@ func TestServeFile(t *testing.T) {
 	is.Equal(stat.Name(), "_about.svelte")
 	is.Equal(stat.Mode(), fs.FileMode(0))
 	is.Equal(stat.IsDir(), false)
-	is.True(stat.ModTime().IsZero())
+	is.Equal(stat.ModTime(), budfstest.FakeTime)
 	is.Equal(stat.Size(), int64(35))
 	is.Equal(stat.Sys(), nil)
 	code, err = fs.ReadFile(bfs, "duo/view/about/_about.svelte")
@@ -1462,6 +1479,2560 @@ func TestCacheMount(t *testing.T) {
 	is.Equal(count["bud/generator/b.txt"], 1, "wrong bud/generator/b.txt mount reads")
 }
 
+func TestSetLogger(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{}
+	bfs := budfs.New(fsys, log.Discard)
+	bfs.GenerateFile("a.txt", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("a")
+		return nil
+	})
+	var entries []log.Entry
+	handler := log.New(handlerFunc(func(entry log.Entry) {
+		entries = append(entries, entry)
+	}))
+	bfs.SetLogger(handler)
+	code, err := fs.ReadFile(bfs, "a.txt")
+	is.NoErr(err)
+	is.Equal(string(code), "a")
+	is.True(len(entries) > 0)
+	// Swap back to discard
+	bfs.SetLogger(log.Discard)
+	count := len(entries)
+	bfs.Change("a.txt")
+	code, err = fs.ReadFile(bfs, "a.txt")
+	is.NoErr(err)
+	is.Equal(string(code), "a")
+	is.Equal(len(entries), count)
+}
+
+type handlerFunc func(entry log.Entry)
+
+func (fn handlerFunc) Log(entry log.Entry) {
+	fn(entry)
+}
+
+func TestGenerateFileConcurrent(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	var count int32
+	release := make(chan struct{})
+	bfs.GenerateFile("a.txt", func(fsys budfs.FS, file *budfs.File) error {
+		atomic.AddInt32(&count, 1)
+		<-release
+		file.Data = []byte("a")
+		return nil
+	})
+	eg := new(errgroup.Group)
+	codes := make([]string, 10)
+	for i := range codes {
+		i := i
+		eg.Go(func() error {
+			code, err := fs.ReadFile(bfs, "a.txt")
+			if err != nil {
+				return err
+			}
+			codes[i] = string(code)
+			return nil
+		})
+	}
+	// Give the readers time to pile up on the generator
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	is.NoErr(eg.Wait())
+	for _, code := range codes {
+		is.Equal(code, "a")
+	}
+	is.Equal(atomic.LoadInt32(&count), int32(1))
+}
+
+func TestGenerateDirOnce(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	count := 0
+	bfs.GenerateDirOnce("bud/view", func(fsys budfs.FS, dir *budfs.Dir) error {
+		count++
+		dir.GenerateFile("index.svelte", func(fsys budfs.FS, file *budfs.File) error {
+			file.Data = []byte("<h1>index</h1>")
+			return nil
+		})
+		return nil
+	})
+	code, err := fs.ReadFile(bfs, "bud/view/index.svelte")
+	is.NoErr(err)
+	is.Equal(string(code), "<h1>index</h1>")
+	bfs.Change("bud/view", "bud/view/index.svelte")
+	code, err = fs.ReadFile(bfs, "bud/view/index.svelte")
+	is.NoErr(err)
+	is.Equal(string(code), "<h1>index</h1>")
+	des, err := fs.ReadDir(bfs, "bud/view")
+	is.NoErr(err)
+	is.Equal(len(des), 1)
+	is.Equal(count, 1)
+}
+
+func TestReorder(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	for _, path := range []string{"bud/b.js", "bud/a.svelte", "bud/c.css", "bud/a.js"} {
+		path := path
+		bfs.GenerateFile(path, func(fsys budfs.FS, file *budfs.File) error {
+			file.Data = []byte(path)
+			return nil
+		})
+	}
+	names := func(des []fs.DirEntry) (names []string) {
+		for _, de := range des {
+			names = append(names, de.Name())
+		}
+		return names
+	}
+	des, err := fs.ReadDir(bfs, "bud")
+	is.NoErr(err)
+	is.Equal(names(des), []string{"a.js", "a.svelte", "b.js", "c.css"})
+	bfs.Reorder(budfs.SortByExtension)
+	des, err = fs.ReadDir(bfs, "bud")
+	is.NoErr(err)
+	is.Equal(names(des), []string{"c.css", "a.js", "b.js", "a.svelte"})
+	bfs.Reorder(func(a, b string) bool { return a > b })
+	des, err = fs.ReadDir(bfs, "bud")
+	is.NoErr(err)
+	is.Equal(names(des), []string{"c.css", "b.js", "a.svelte", "a.js"})
+	bfs.Reorder(budfs.SortAlphabetical)
+	des, err = fs.ReadDir(bfs, "bud")
+	is.NoErr(err)
+	is.Equal(names(des), []string{"a.js", "a.svelte", "b.js", "c.css"})
+	// Reordering through a namespace or a copy applies to the whole filesystem
+	bfs.Namespace("bud").Reorder(budfs.SortByExtension)
+	des, err = fs.ReadDir(bfs, "bud")
+	is.NoErr(err)
+	is.Equal(names(des), []string{"c.css", "a.js", "b.js", "a.svelte"})
+	bfs.WithContext(context.Background()).Reorder(budfs.SortAlphabetical)
+	des, err = fs.ReadDir(bfs.Namespace("bud"), ".")
+	is.NoErr(err)
+	is.Equal(names(des), []string{"a.js", "a.svelte", "b.js", "c.css"})
+	// Sync still writes every file
+	out := virtual.Map{}
+	is.NoErr(bfs.Sync(out, "bud"))
+	for _, path := range []string{"bud/b.js", "bud/a.svelte", "bud/c.css", "bud/a.js"} {
+		code, err := fs.ReadFile(out, path)
+		is.NoErr(err)
+		is.Equal(string(code), path)
+	}
+}
+
+func TestReadDirOpens(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	for _, path := range []string{"bud/b.js", "bud/a.svelte", "bud/c.css"} {
+		path := path
+		bfs.GenerateFile(path, func(fsys budfs.FS, file *budfs.File) error {
+			file.Data = []byte(path)
+			return nil
+		})
+	}
+	bfs.Reorder(budfs.SortByExtension)
+	bfs.GenerateFile("listing.txt", func(fsys budfs.FS, file *budfs.File) error {
+		des, err := fs.ReadDir(fsys, "bud")
+		if err != nil {
+			return err
+		}
+		for _, de := range des {
+			file.Data = append(file.Data, de.Name()+"\n"...)
+		}
+		return nil
+	})
+	var calls []string
+	bfs.Intercept(func(path string, next func() (fs.File, error)) (fs.File, error) {
+		calls = append(calls, path)
+		if path == "secret" {
+			return nil, fs.ErrPermission
+		}
+		return next()
+	})
+	des, err := bfs.ReadDir("bud")
+	is.NoErr(err)
+	is.Equal(len(des), 3)
+	is.Equal(des[0].Name(), "c.css")
+	is.Equal(calls, []string{"bud"})
+	is.Equal(bfs.OpCount(), int64(1))
+	_, err = bfs.ReadDir("secret")
+	is.True(errors.Is(err, fs.ErrPermission))
+	// Generators read directories through the interceptors in the same order
+	calls = calls[:0]
+	data, err := fs.ReadFile(bfs, "listing.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "c.css\nb.js\na.svelte\n")
+	is.Equal(calls, []string{"listing.txt", "bud"})
+}
+
+func TestSetMaxCacheBytes(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	count := map[string]int{}
+	for _, path := range []string{"a.txt", "b.txt", "c.txt"} {
+		path := path
+		bfs.GenerateFile(path, func(fsys budfs.FS, file *budfs.File) error {
+			count[path]++
+			file.Data = []byte("1234")
+			return nil
+		})
+	}
+	bfs.SetMaxCacheBytes(8)
+	for _, path := range []string{"a.txt", "b.txt", "c.txt"} {
+		_, err := fs.ReadFile(bfs, path)
+		is.NoErr(err)
+	}
+	is.Equal(bfs.CacheBytes(), int64(8))
+	// a.txt was evicted, b.txt and c.txt are cached
+	for _, path := range []string{"c.txt", "b.txt", "a.txt"} {
+		_, err := fs.ReadFile(bfs, path)
+		is.NoErr(err)
+	}
+	is.Equal(count["a.txt"], 2)
+	is.Equal(count["b.txt"], 1)
+	is.Equal(count["c.txt"], 1)
+	is.Equal(bfs.CacheBytes(), int64(8))
+	// Remove the budget
+	bfs.SetMaxCacheBytes(0)
+	_, err := fs.ReadFile(bfs, "c.txt")
+	is.NoErr(err)
+	is.Equal(count["c.txt"], 2)
+	is.Equal(bfs.CacheBytes(), int64(12))
+}
+
+func TestDeclareDepends(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	count := map[string]int{}
+	bfs.GenerateFile("bud/a.txt", func(fsys budfs.FS, file *budfs.File) error {
+		count["bud/a.txt"]++
+		file.Data = []byte("a")
+		return nil
+	})
+	bfs.GenerateFile("bud/b.txt", func(fsys budfs.FS, file *budfs.File) error {
+		count["bud/b.txt"]++
+		file.Data = []byte("b")
+		return nil
+	})
+	bfs.DeclareDepends("bud/a.txt", "bud/b.txt")
+	// Warm the dependency's cache
+	_, err := fs.ReadFile(bfs, "bud/b.txt")
+	is.NoErr(err)
+	is.Equal(count["bud/b.txt"], 1)
+	// Generating the dependent regenerates the dependency
+	code, err := fs.ReadFile(bfs, "bud/a.txt")
+	is.NoErr(err)
+	is.Equal(string(code), "a")
+	is.Equal(count["bud/a.txt"], 1)
+	is.Equal(count["bud/b.txt"], 2)
+	// Changing the dependency invalidates the dependent
+	bfs.Change("bud/b.txt")
+	_, err = fs.ReadFile(bfs, "bud/a.txt")
+	is.NoErr(err)
+	is.Equal(count["bud/a.txt"], 2)
+	is.Equal(count["bud/b.txt"], 3)
+}
+
+func TestDeclareDependsCycle(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	bfs.GenerateFile("a.txt", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("a")
+		return nil
+	})
+	bfs.GenerateFile("b.txt", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("b")
+		return nil
+	})
+	bfs.DeclareDepends("a.txt", "b.txt")
+	bfs.DeclareDepends("b.txt", "a.txt")
+	_, err := fs.ReadFile(bfs, "a.txt")
+	is.True(err != nil)
+	is.In(err.Error(), "dependency cycle")
+}
+
+func TestGenerateFileTemplate(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{
+		"view/index.svelte": &virtual.File{Data: []byte("<h1>index</h1>")},
+	}
+	bfs := budfs.New(fsys, log.Discard)
+	bfs.GenerateFileTemplate("bud/view.txt", `view: {{ .View }}`, func(fsys budfs.FS) (interface{}, error) {
+		code, err := fs.ReadFile(fsys, "view/index.svelte")
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"View": string(code)}, nil
+	})
+	code, err := fs.ReadFile(bfs, "bud/view.txt")
+	is.NoErr(err)
+	is.Equal(string(code), "view: <h1>index</h1>")
+	// Data errors are returned
+	bfs.GenerateFileTemplate("bud/missing.txt", `{{ . }}`, func(fsys budfs.FS) (interface{}, error) {
+		return fs.ReadFile(fsys, "view/missing.svelte")
+	})
+	_, err = fs.ReadFile(bfs, "bud/missing.txt")
+	is.True(errors.Is(err, fs.ErrNotExist))
+	// Invalid templates panic at registration time
+	defer func() {
+		is.True(recover() != nil)
+	}()
+	bfs.GenerateFileTemplate("bud/invalid.txt", `{{ .View `, func(fsys budfs.FS) (interface{}, error) {
+		return nil, nil
+	})
+}
+
+func TestRequire(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{
+		"view/index.svelte": &virtual.File{Data: []byte("<h1>index</h1>")},
+	}
+	bfs := budfs.New(fsys, log.Discard)
+	bfs.GenerateFile("bud/index.txt", func(fsys budfs.FS, file *budfs.File) (err error) {
+		file.Data, err = fsys.Require("view/index.svelte")
+		return err
+	})
+	var requiredErr *budfs.RequiredFileError
+	bfs.GenerateFile("bud/about.txt", func(fsys budfs.FS, file *budfs.File) (err error) {
+		file.Data, err = fsys.Require("view/about.svelte")
+		is.True(errors.As(err, &requiredErr))
+		return err
+	})
+	code, err := fs.ReadFile(bfs, "bud/index.txt")
+	is.NoErr(err)
+	is.Equal(string(code), "<h1>index</h1>")
+	code, err = fs.ReadFile(bfs, "bud/about.txt")
+	is.True(err != nil)
+	is.Equal(code, nil)
+	is.True(errors.Is(err, fs.ErrNotExist))
+	is.In(err.Error(), `"bud/about.txt" generator requires "view/about.svelte" but it does not exist`)
+	is.True(requiredErr != nil)
+	is.Equal(requiredErr.Generator, "bud/about.txt")
+	is.Equal(requiredErr.Path, "view/about.svelte")
+}
+
+func TestDirGenerateFileFromTemplate(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{
+		"view/index.svelte": &virtual.File{Data: []byte("<h1>index</h1>")},
+	}
+	bfs := budfs.New(fsys, log.Discard)
+	tmpl := template.Must(template.New("view").Parse(`view: {{ . }}`))
+	bfs.GenerateDir("bud/view", func(fsys budfs.FS, dir *budfs.Dir) error {
+		dir.GenerateFileFromTemplate("static.txt", tmpl, "static")
+		dir.GenerateFileFromTemplateFunc("index.txt", tmpl, func(fsys budfs.FS) (interface{}, error) {
+			code, err := fs.ReadFile(fsys, "view/index.svelte")
+			if err != nil {
+				return nil, err
+			}
+			return string(code), nil
+		})
+		return nil
+	})
+	code, err := fs.ReadFile(bfs, "bud/view/static.txt")
+	is.NoErr(err)
+	is.Equal(string(code), "view: static")
+	code, err = fs.ReadFile(bfs, "bud/view/index.txt")
+	is.NoErr(err)
+	is.Equal(string(code), "view: <h1>index</h1>")
+}
+
+func TestGenerateBinaryFile(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	bfs.GenerateBinaryFile("bud/favicon.ico", func(fsys budfs.FS, w io.Writer) error {
+		_, err := w.Write([]byte{0x00, 0x00, 0x01, 0x00})
+		return err
+	})
+	bfs.GenerateBinaryFile("bud/error.bin", func(fsys budfs.FS, w io.Writer) error {
+		return errors.New("unable to compile")
+	})
+	code, err := fs.ReadFile(bfs, "bud/favicon.ico")
+	is.NoErr(err)
+	is.Equal(code, []byte{0x00, 0x00, 0x01, 0x00})
+	_, err = fs.ReadFile(bfs, "bud/error.bin")
+	is.True(err != nil)
+	is.In(err.Error(), "unable to compile")
+}
+
+type ctxKey struct{}
+
+func TestServeFileContext(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	bfs.ServeFileContext("bud/request", func(ctx context.Context, fsys budfs.FS, file *budfs.File) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		value, _ := ctx.Value(ctxKey{}).(string)
+		file.Data = []byte(file.Relative() + ":" + value)
+		return nil
+	})
+	ctx := context.WithValue(context.Background(), ctxKey{}, "trace-1")
+	file, err := bfs.OpenContext(ctx, "bud/request/a.txt")
+	is.NoErr(err)
+	code, err := io.ReadAll(file)
+	is.NoErr(err)
+	is.NoErr(file.Close())
+	is.Equal(string(code), "a.txt:trace-1")
+	// Open uses a background context
+	code, err = fs.ReadFile(bfs, "bud/request/b.txt")
+	is.NoErr(err)
+	is.Equal(string(code), "b.txt:")
+	// Cancelled contexts are passed through
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = bfs.OpenContext(ctx, "bud/request/c.txt")
+	is.True(errors.Is(err, context.Canceled))
+}
+
+func TestErrNotGenerated(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	ready := false
+	bfs.GenerateFile("bud/a.txt", func(fsys budfs.FS, file *budfs.File) error {
+		if !ready {
+			return budfs.ErrNotGenerated
+		}
+		file.Data = []byte("a")
+		return nil
+	})
+	_, err := bfs.Open("bud/a.txt")
+	is.True(errors.Is(err, budfs.ErrNotGenerated))
+	is.Equal(err.Error(), `budfs: unable to open "bud/a.txt". not yet generated`)
+	is.True(!errors.Is(err, fs.ErrNotExist))
+	// Retry later
+	ready = true
+	code, err := fs.ReadFile(bfs, "bud/a.txt")
+	is.NoErr(err)
+	is.Equal(string(code), "a")
+}
+
+func TestOpenGroup(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{
+		"view/index.svelte": &virtual.File{Data: []byte("<h1>index</h1>")},
+	}
+	bfs := budfs.New(fsys, log.Discard)
+	for _, path := range []string{"bud/a.txt", "bud/b.txt", "bud/c.txt"} {
+		path := path
+		bfs.GenerateFile(path, func(fsys budfs.FS, file *budfs.File) error {
+			file.Data = []byte(path)
+			return nil
+		})
+	}
+	files, err := bfs.OpenGroup("bud/a.txt", "view/index.svelte", "bud/c.txt", "bud/b.txt")
+	is.NoErr(err)
+	is.Equal(len(files), 4)
+	var contents []string
+	for _, file := range files {
+		code, err := io.ReadAll(file)
+		is.NoErr(err)
+		is.NoErr(file.Close())
+		contents = append(contents, string(code))
+	}
+	is.Equal(contents, []string{"bud/a.txt", "<h1>index</h1>", "bud/c.txt", "bud/b.txt"})
+	// Any error fails the group
+	files, err = bfs.OpenGroup("bud/a.txt", "bud/missing.txt")
+	is.True(errors.Is(err, fs.ErrNotExist))
+	is.Equal(files, nil)
+}
+
+func TestReadAll(t *testing.T) {
+	is := is.New(t)
+	fsys := fstest.MapFS{
+		"view/index.svelte":       &fstest.MapFile{Data: []byte("<h1>index</h1>")},
+		"view/about/index.svelte": &fstest.MapFile{Data: []byte("<h1>about</h1>")},
+		"view/main.go":            &fstest.MapFile{Data: []byte("package main")},
+	}
+	bfs := budfs.New(fsys, log.Discard)
+	count := 0
+	bfs.GenerateFile("bud/views.txt", func(fsys budfs.FS, file *budfs.File) error {
+		count++
+		files, err := fsys.ReadAll("view/**.svelte")
+		if err != nil {
+			return err
+		}
+		file.Data = []byte(fmt.Sprintf("%s%s%d", files["view/index.svelte"], files["view/about/index.svelte"], len(files)))
+		return nil
+	})
+	code, err := fs.ReadFile(bfs, "bud/views.txt")
+	is.NoErr(err)
+	is.Equal(string(code), "<h1>index</h1><h1>about</h1>2")
+	is.Equal(count, 1)
+	// Changing a matched file invalidates the generator
+	bfs.Change("view/about/index.svelte")
+	_, err = fs.ReadFile(bfs, "bud/views.txt")
+	is.NoErr(err)
+	is.Equal(count, 2)
+}
+
+func TestDebug(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{
+		"view/index.svelte": &virtual.File{Data: []byte("<h1>index</h1>")},
+	}
+	bfs := budfs.New(fsys, log.Discard)
+	bfs.GenerateFile("bud/view.txt", func(fsys budfs.FS, file *budfs.File) (err error) {
+		file.Data, err = fs.ReadFile(fsys, "view/index.svelte")
+		return err
+	})
+	bfs.ServeFile("bud/public", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte(file.Relative())
+		return nil
+	})
+	_, err := fs.ReadFile(bfs, "bud/view.txt")
+	is.NoErr(err)
+	buf := new(bytes.Buffer)
+	is.NoErr(bfs.Debug(buf))
+	is.Equal(buf.String(), `generators:
+  bud/public mode=d--------- type=*budfs.fileServer
+  bud/view.txt mode=---------- type=*budfs.fileGenerator
+cache:
+  bud/view.txt size=14
+links:
+  bud/view.txt selectors=0
+    -> view/index.svelte
+cache bytes: 14
+`)
+}
+
+// openRecorder records the paths that were opened
+type openRecorder struct {
+	fs.FS
+	mu     sync.Mutex
+	opened []string
+}
+
+func (r *openRecorder) Open(name string) (fs.File, error) {
+	r.mu.Lock()
+	r.opened = append(r.opened, name)
+	r.mu.Unlock()
+	return r.FS.Open(name)
+}
+
+func TestGlobSkipDir(t *testing.T) {
+	is := is.New(t)
+	fsys := &openRecorder{
+		FS: fstest.MapFS{
+			"view/users/index.svelte":          &fstest.MapFile{Data: []byte("<h1>users</h1>")},
+			"view/posts/index.svelte":          &fstest.MapFile{Data: []byte("<h1>posts</h1>")},
+			"view/posts/comments/index.svelte": &fstest.MapFile{Data: []byte("<h1>comments</h1>")},
+		},
+	}
+	bfs := budfs.New(fsys, log.Discard)
+	bfs.GenerateFile("bud/users.txt", func(fsys budfs.FS, file *budfs.File) error {
+		matches, err := fs.Glob(fsys, "view/us*/index.svelte")
+		if err != nil {
+			return err
+		}
+		file.Data = []byte(strings.Join(matches, " "))
+		return nil
+	})
+	data, err := fs.ReadFile(bfs, "bud/users.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "view/users/index.svelte")
+	for _, opened := range fsys.opened {
+		is.True(!strings.HasPrefix(opened, "view/posts/"))
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{
+		"view/index.svelte": &virtual.File{Data: []byte("<h1>index</h1>")},
+	}
+	bfs := budfs.New(fsys, log.Discard)
+	app1 := bfs.Namespace("bud/app1")
+	app2 := bfs.Namespace("bud/app2")
+	count := 0
+	app1.GenerateFile("view.txt", func(fsys budfs.FS, file *budfs.File) (err error) {
+		count++
+		// Generators see paths relative to the root
+		file.Data, err = fs.ReadFile(fsys, "view/index.svelte")
+		return err
+	})
+	app2.GenerateDir("web", func(fsys budfs.FS, dir *budfs.Dir) error {
+		dir.GenerateFile("web.txt", func(fsys budfs.FS, file *budfs.File) error {
+			file.Data = []byte(file.Target())
+			return nil
+		})
+		return nil
+	})
+	data, err := fs.ReadFile(app1, "view.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "<h1>index</h1>")
+	// Shares the cache with the parent
+	data, err = fs.ReadFile(bfs, "bud/app1/view.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "<h1>index</h1>")
+	is.Equal(count, 1)
+	data, err = fs.ReadFile(app2, "web/web.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "bud/app2/web/web.txt")
+	des, err := fs.ReadDir(bfs, "bud")
+	is.NoErr(err)
+	is.Equal(len(des), 2)
+	// Namespaces can be nested
+	nested := bfs.Namespace("bud").Namespace("app1")
+	data, err = fs.ReadFile(nested, "view.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "<h1>index</h1>")
+	// Change is namespaced too
+	app1.Change("view.txt")
+	data, err = fs.ReadFile(bfs, "bud/app1/view.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "<h1>index</h1>")
+	is.Equal(count, 2)
+	// Missing files
+	_, err = fs.ReadFile(app1, "missing.txt")
+	is.True(errors.Is(err, fs.ErrNotExist))
+}
+
+func TestAlias(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	count := 0
+	bfs.GenerateFile("bud/view/index.js", func(fsys budfs.FS, file *budfs.File) error {
+		count++
+		file.Data = []byte("console.log('index')")
+		return nil
+	})
+	bfs.Alias("bud/public/index.js", "bud/view/index.js")
+	data, err := fs.ReadFile(bfs, "bud/public/index.js")
+	is.NoErr(err)
+	is.Equal(string(data), "console.log('index')")
+	data, err = fs.ReadFile(bfs, "bud/view/index.js")
+	is.NoErr(err)
+	is.Equal(string(data), "console.log('index')")
+	is.Equal(count, 1)
+	// Alias shows up in the parent directory
+	des, err := fs.ReadDir(bfs, "bud/public")
+	is.NoErr(err)
+	is.Equal(len(des), 1)
+	is.Equal(des[0].Name(), "index.js")
+	// Changes to the target are reflected through the alias
+	bfs.Change("bud/view/index.js")
+	data, err = fs.ReadFile(bfs, "bud/public/index.js")
+	is.NoErr(err)
+	is.Equal(string(data), "console.log('index')")
+	is.Equal(count, 2)
+	is.Equal(bfs.Aliases(), map[string]string{
+		"bud/public/index.js": "bud/view/index.js",
+	})
+	// Aliases to missing files don't exist
+	bfs.Alias("bud/public/missing.js", "bud/view/missing.js")
+	_, err = fs.ReadFile(bfs, "bud/public/missing.js")
+	is.True(errors.Is(err, fs.ErrNotExist))
+}
+
+func TestOverlay(t *testing.T) {
+	is := is.New(t)
+	source := virtual.Map{
+		"view/layout.svelte": &virtual.File{Data: []byte("default layout")},
+		"view/index.svelte":  &virtual.File{Data: []byte("index")},
+	}
+	custom := virtual.Map{
+		"view/layout.svelte": &virtual.File{Data: []byte("custom layout")},
+		"bud/view.txt":       &virtual.File{Data: []byte("custom view")},
+	}
+	bfs := budfs.New(source, log.Discard)
+	bfs.GenerateFile("bud/view.txt", func(fsys budfs.FS, file *budfs.File) (err error) {
+		file.Data, err = fs.ReadFile(fsys, "view/layout.svelte")
+		return err
+	})
+	bfs.Overlay(custom, 50)
+	// Overlay overrides the source
+	data, err := fs.ReadFile(bfs, "view/layout.svelte")
+	is.NoErr(err)
+	is.Equal(string(data), "custom layout")
+	data, err = fs.ReadFile(bfs, "view/index.svelte")
+	is.NoErr(err)
+	is.Equal(string(data), "index")
+	// Generated files override the overlay, but see the overlay
+	data, err = fs.ReadFile(bfs, "bud/view.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "custom layout")
+	// Higher priorities override generated files
+	bfs.Overlay(virtual.Map{
+		"bud/view.txt": &virtual.File{Data: []byte("override")},
+	}, budfs.PriorityGenerated+1)
+	data, err = fs.ReadFile(bfs, "bud/view.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "override")
+}
+
+func TestIntercept(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{
+		"view/index.svelte": &virtual.File{Data: []byte("<h1>index</h1>")},
+	}
+	bfs := budfs.New(fsys, log.Discard)
+	count := 0
+	bfs.GenerateFile("bud/view.txt", func(fsys budfs.FS, file *budfs.File) (err error) {
+		count++
+		file.Data, err = fs.ReadFile(fsys, "view/index.svelte")
+		return err
+	})
+	var calls []string
+	bfs.Intercept(func(path string, next func() (fs.File, error)) (fs.File, error) {
+		calls = append(calls, "first:"+path)
+		return next()
+	})
+	bfs.Intercept(func(path string, next func() (fs.File, error)) (fs.File, error) {
+		calls = append(calls, "second:"+path)
+		if path == "secret.txt" {
+			return nil, fs.ErrPermission
+		}
+		return next()
+	})
+	data, err := fs.ReadFile(bfs, "bud/view.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "<h1>index</h1>")
+	is.Equal(calls, []string{
+		"first:bud/view.txt",
+		"second:bud/view.txt",
+		"first:view/index.svelte",
+		"second:view/index.svelte",
+	})
+	// Cached opens are intercepted too
+	calls = calls[:0]
+	data, err = fs.ReadFile(bfs, "bud/view.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "<h1>index</h1>")
+	is.Equal(count, 1)
+	is.Equal(calls, []string{"first:bud/view.txt", "second:bud/view.txt"})
+	// Interceptors can short-circuit
+	_, err = bfs.Open("secret.txt")
+	is.True(errors.Is(err, fs.ErrPermission))
+}
+
+func TestCacheKey(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	bfs.GenerateFile("bud/view.txt", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("view")
+		return nil
+	})
+	bfs.GenerateDir("bud/controller", func(fsys budfs.FS, dir *budfs.Dir) error {
+		dir.GenerateFile("controller.go", func(fsys budfs.FS, file *budfs.File) error {
+			file.Data = []byte("package controller")
+			return nil
+		})
+		return nil
+	})
+	bfs.ServeFile("bud/public", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte(file.Relative())
+		return nil
+	})
+	bfs.Alias("bud/alias.txt", "bud/view.txt")
+	test := func(path, expect string, found bool) {
+		t.Helper()
+		key, ok := bfs.CacheKey(path)
+		is.Equal(ok, found)
+		is.Equal(key, expect)
+	}
+	test("bud/view.txt", "bud/view.txt", true)
+	test("bud/view.txt/nested", "", false)
+	test("bud/controller", "bud/controller", true)
+	// Before the directory generator has run
+	test("bud/controller/controller.go", "bud/controller", true)
+	test("bud/public/main.css", "bud/public/main.css", true)
+	test("bud/public", "", false)
+	test("bud/alias.txt", "bud/view.txt", true)
+	test("bud", "", false)
+	test("view/index.svelte", "", false)
+	// After the directory generator has run
+	_, err := fs.ReadFile(bfs, "bud/controller/controller.go")
+	is.NoErr(err)
+	test("bud/controller/controller.go", "bud/controller/controller.go", true)
+	// Namespaced keys are relative to the namespace
+	key, ok := bfs.Namespace("bud").CacheKey("view.txt")
+	is.True(ok)
+	is.Equal(key, "view.txt")
+}
+
+func TestGenerateDirEager(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	count := 0
+	err := bfs.GenerateDirEager("bud/view", func(fsys budfs.FS, dir *budfs.Dir) error {
+		count++
+		dir.GenerateFile("index.js", func(fsys budfs.FS, file *budfs.File) error {
+			file.Data = []byte("index")
+			return nil
+		})
+		dir.GenerateFile("about.js", func(fsys budfs.FS, file *budfs.File) error {
+			file.Data = []byte("about")
+			return nil
+		})
+		return nil
+	})
+	is.NoErr(err)
+	is.Equal(count, 1)
+	des, err := fs.ReadDir(bfs, "bud/view")
+	is.NoErr(err)
+	is.Equal(len(des), 2)
+	is.Equal(des[0].Name(), "about.js")
+	is.Equal(des[1].Name(), "index.js")
+	is.Equal(count, 1)
+	// Errors are returned at registration and again on open
+	err = bfs.GenerateDirEager("bud/error", func(fsys budfs.FS, dir *budfs.Dir) error {
+		return fmt.Errorf("unable to generate")
+	})
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), `"bud/error"`))
+	is.True(strings.Contains(err.Error(), "unable to generate"))
+	_, err = fs.ReadDir(bfs, "bud/error")
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "unable to generate"))
+}
+
+func TestCacheDumpLoad(t *testing.T) {
+	is := is.New(t)
+	register := func(bfs *budfs.FileSystem, count *int) {
+		bfs.GenerateFile("bud/view.txt", func(fsys budfs.FS, file *budfs.File) error {
+			*count++
+			file.Data = []byte("view")
+			return nil
+		})
+		bfs.GenerateDir("bud/controller", func(fsys budfs.FS, dir *budfs.Dir) error {
+			dir.GenerateFile("controller.go", func(fsys budfs.FS, file *budfs.File) error {
+				*count++
+				file.Data = []byte("package controller")
+				return nil
+			})
+			return nil
+		})
+	}
+	count := 0
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	register(bfs, &count)
+	bfs.GenerateFile("bud/removed.txt", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("removed")
+		return nil
+	})
+	for _, path := range []string{"bud/view.txt", "bud/controller/controller.go", "bud/removed.txt"} {
+		_, err := fs.ReadFile(bfs, path)
+		is.NoErr(err)
+	}
+	is.Equal(count, 2)
+	buf := new(bytes.Buffer)
+	is.NoErr(bfs.CacheDump(buf))
+	// Restore into a new filesystem
+	count = 0
+	bfs = budfs.New(virtual.Map{}, log.Discard)
+	register(bfs, &count)
+	is.NoErr(bfs.CacheLoad(buf))
+	is.Equal(bfs.CacheBytes(), int64(len("view")+len("package controller")))
+	data, err := fs.ReadFile(bfs, "bud/view.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "view")
+	data, err = fs.ReadFile(bfs, "bud/controller/controller.go")
+	is.NoErr(err)
+	is.Equal(string(data), "package controller")
+	is.Equal(count, 0)
+	// Changes still invalidate restored entries
+	bfs.Change("bud/view.txt")
+	_, err = fs.ReadFile(bfs, "bud/view.txt")
+	is.NoErr(err)
+	is.Equal(count, 1)
+}
+
+func TestWithSharedCache(t *testing.T) {
+	is := is.New(t)
+	cache := vcache.New()
+	count := 0
+	newFS := func() *budfs.FileSystem {
+		bfs := budfs.New(virtual.Map{}, log.Discard).WithSharedCache(cache)
+		bfs.GenerateFile("bud/view.txt", func(fsys budfs.FS, file *budfs.File) error {
+			count++
+			file.Data = []byte("view")
+			return nil
+		})
+		return bfs
+	}
+	bfs1 := newFS()
+	bfs2 := newFS()
+	data, err := fs.ReadFile(bfs1, "bud/view.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "view")
+	data, err = fs.ReadFile(bfs2, "bud/view.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "view")
+	is.Equal(count, 1)
+	is.Equal(bfs2.CacheBytes(), int64(4))
+	// Changes evict the entry for both filesystems
+	bfs1.Change("bud/view.txt")
+	_, err = fs.ReadFile(bfs2, "bud/view.txt")
+	is.NoErr(err)
+	is.Equal(count, 2)
+}
+
+func TestMountRoot(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	err := bfs.Mount("bud/public", fstest.MapFS{
+		"favicon.ico":  &fstest.MapFile{Data: []byte("favicon")},
+		"css/main.css": &fstest.MapFile{Data: []byte("main")},
+	})
+	is.NoErr(err)
+	data, err := fs.ReadFile(bfs, "bud/public/favicon.ico")
+	is.NoErr(err)
+	is.Equal(string(data), "favicon")
+	data, err = fs.ReadFile(bfs, "bud/public/css/main.css")
+	is.NoErr(err)
+	is.Equal(string(data), "main")
+	des, err := fs.ReadDir(bfs, "bud/public")
+	is.NoErr(err)
+	is.Equal(len(des), 2)
+	is.Equal(des[0].Name(), "css")
+	is.Equal(des[1].Name(), "favicon.ico")
+	_, err = fs.ReadFile(bfs, "bud/public/missing.css")
+	is.True(errors.Is(err, fs.ErrNotExist))
+	// Invalid mount paths
+	is.True(errors.Is(bfs.Mount(".", fstest.MapFS{}), fs.ErrInvalid))
+	is.True(errors.Is(bfs.Mount("/bud", fstest.MapFS{}), fs.ErrInvalid))
+}
+
+func TestUnmount(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	bfs.GenerateFile("bud/view.txt", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("view")
+		return nil
+	})
+	err := bfs.Mount("bud/public", fstest.MapFS{
+		"favicon.ico": &fstest.MapFile{Data: []byte("favicon")},
+	})
+	is.NoErr(err)
+	data, err := fs.ReadFile(bfs, "bud/public/favicon.ico")
+	is.NoErr(err)
+	is.Equal(string(data), "favicon")
+	is.NoErr(bfs.Unmount("bud/public"))
+	_, err = fs.ReadFile(bfs, "bud/public/favicon.ico")
+	is.True(errors.Is(err, fs.ErrNotExist))
+	des, err := fs.ReadDir(bfs, "bud")
+	is.NoErr(err)
+	is.Equal(len(des), 1)
+	is.Equal(des[0].Name(), "view.txt")
+	// Unmounting again fails
+	is.True(errors.Is(bfs.Unmount("bud/public"), budfs.ErrNotMounted))
+	// Generators aren't mount points
+	is.True(errors.Is(bfs.Unmount("bud/view.txt"), budfs.ErrNotMounted))
+	is.True(errors.Is(bfs.Unmount("bud"), budfs.ErrNotMounted))
+}
+
+func TestFileDirCount(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	is.Equal(bfs.FileCount(), 0)
+	is.Equal(bfs.DirCount(), 0)
+	bfs.GenerateFile("bud/view.txt", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("view")
+		return nil
+	})
+	bfs.GenerateFile("bud/public/main.css", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("main")
+		return nil
+	})
+	bfs.GenerateDir("bud/controller", func(fsys budfs.FS, dir *budfs.Dir) error {
+		dir.GenerateFile("controller.go", func(fsys budfs.FS, file *budfs.File) error {
+			file.Data = []byte("package controller")
+			return nil
+		})
+		return nil
+	})
+	// Filler directories aren't counted
+	is.Equal(bfs.FileCount(), 2)
+	is.Equal(bfs.DirCount(), 1)
+	// Files registered by directory generators are counted once generated
+	_, err := fs.ReadFile(bfs, "bud/controller/controller.go")
+	is.NoErr(err)
+	is.Equal(bfs.FileCount(), 3)
+	is.Equal(bfs.DirCount(), 1)
+}
+
+func TestCacheLoadVersion(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	is.Equal(bfs.Version(), budfs.Version)
+	buf := new(bytes.Buffer)
+	enc := gob.NewEncoder(buf)
+	is.NoErr(enc.Encode(struct{ Version string }{"0"}))
+	is.NoErr(enc.Encode([]*virtual.File{}))
+	err := bfs.CacheLoad(buf)
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), `version "0"`))
+}
+
+func TestCounters(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	bfs.GenerateFile("bud/view.txt", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("view")
+		return nil
+	})
+	bfs.GenerateFile("bud/controller.txt", func(fsys budfs.FS, file *budfs.File) error {
+		view, err := fs.ReadFile(fsys, "bud/view.txt")
+		if err != nil {
+			return err
+		}
+		file.Data = append([]byte("controller "), view...)
+		return nil
+	})
+	is.Equal(bfs.OpCount(), int64(0))
+	is.Equal(bfs.GenerateCount(), int64(0))
+	_, err := fs.ReadFile(bfs, "bud/controller.txt")
+	is.NoErr(err)
+	is.Equal(bfs.OpCount(), int64(2))
+	is.Equal(bfs.GenerateCount(), int64(2))
+	// Cache hits count as opens, but not generates
+	_, err = fs.ReadFile(bfs, "bud/controller.txt")
+	is.NoErr(err)
+	is.Equal(bfs.OpCount(), int64(3))
+	is.Equal(bfs.GenerateCount(), int64(2))
+	// Namespaces share the counters
+	_, err = fs.ReadFile(bfs.Namespace("bud"), "view.txt")
+	is.NoErr(err)
+	is.Equal(bfs.OpCount(), int64(4))
+	bfs.ResetCounters()
+	is.Equal(bfs.OpCount(), int64(0))
+	is.Equal(bfs.GenerateCount(), int64(0))
+}
+
+func TestWarmFromDir(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	is.NoErr(os.MkdirAll(filepath.Join(dir, "bud", "controller"), 0755))
+	is.NoErr(os.WriteFile(filepath.Join(dir, "bud", "view.txt"), []byte("cached view"), 0644))
+	is.NoErr(os.WriteFile(filepath.Join(dir, "bud", "controller", "controller.go"), []byte("package cached"), 0644))
+	is.NoErr(os.WriteFile(filepath.Join(dir, "bud", "removed.txt"), []byte("removed"), 0644))
+	count := 0
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	bfs.GenerateFile("bud/view.txt", func(fsys budfs.FS, file *budfs.File) error {
+		count++
+		file.Data = []byte("view")
+		return nil
+	})
+	bfs.GenerateDir("bud/controller", func(fsys budfs.FS, dir *budfs.Dir) error {
+		dir.GenerateFile("controller.go", func(fsys budfs.FS, file *budfs.File) error {
+			count++
+			file.Data = []byte("package controller")
+			return nil
+		})
+		return nil
+	})
+	is.NoErr(bfs.WarmFromDir(dir))
+	is.Equal(bfs.CacheBytes(), int64(len("cached view")+len("package cached")))
+	data, err := fs.ReadFile(bfs, "bud/view.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "cached view")
+	data, err = fs.ReadFile(bfs, "bud/controller/controller.go")
+	is.NoErr(err)
+	is.Equal(string(data), "package cached")
+	is.Equal(count, 0)
+	_, err = fs.ReadFile(bfs, "bud/removed.txt")
+	is.True(errors.Is(err, fs.ErrNotExist))
+	// Missing directories fail
+	is.True(bfs.WarmFromDir(filepath.Join(dir, "missing")) != nil)
+}
+
+func TestGenerateFileStream(t *testing.T) {
+	is := is.New(t)
+	fsys := fstest.MapFS{
+		"public/logo.png": &fstest.MapFile{Data: []byte("0123456789")},
+	}
+	bfs := budfs.New(fsys, log.Discard)
+	bfs.GenerateFileStream("bud/public/logo.png", "bud", func(fsys budfs.FS, in io.ReadSeeker, file *budfs.File) error {
+		// Skip the header
+		if _, err := in.Seek(5, io.SeekStart); err != nil {
+			return err
+		}
+		data, err := io.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		file.Data = data
+		return nil
+	})
+	bfs.GenerateFileStream("bud/public/missing.png", "bud", func(fsys budfs.FS, in io.ReadSeeker, file *budfs.File) error {
+		return nil
+	})
+	data, err := fs.ReadFile(bfs, "bud/public/logo.png")
+	is.NoErr(err)
+	is.Equal(string(data), "56789")
+	_, err = fs.ReadFile(bfs, "bud/public/missing.png")
+	is.True(errors.Is(err, fs.ErrNotExist))
+	// Changing the source regenerates the file
+	fsys["public/logo.png"] = &fstest.MapFile{Data: []byte("abcdefghij")}
+	bfs.Change("public/logo.png")
+	data, err = fs.ReadFile(bfs, "bud/public/logo.png")
+	is.NoErr(err)
+	is.Equal(string(data), "fghij")
+	// Nested prefixes strip every segment of the prefix
+	bfs.GenerateFileStream("bud/assets/public/logo.png", "bud/assets", func(fsys budfs.FS, in io.ReadSeeker, file *budfs.File) error {
+		data, err := io.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		file.Data = data
+		return nil
+	})
+	data, err = fs.ReadFile(bfs, "bud/assets/public/logo.png")
+	is.NoErr(err)
+	is.Equal(string(data), "abcdefghij")
+	// Paths outside of the prefix are rejected when they're registered
+	for _, prefix := range []string{"", ".", "public", "bud/", "bud/public/logo.png"} {
+		func() {
+			defer func() {
+				is.True(recover() != nil)
+			}()
+			bfs.GenerateFileStream("bud/public/logo.png", prefix, func(fsys budfs.FS, in io.ReadSeeker, file *budfs.File) error {
+				return nil
+			})
+		}()
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	bfs.GenerateFile("bud/view.go", func(fsys budfs.FS, file *budfs.File) error {
+		return fmt.Errorf("unable to parse view")
+	})
+	bfs.GenerateFile("bud/main.go", func(fsys budfs.FS, file *budfs.File) error {
+		view, err := fs.ReadFile(fsys, "bud/view.go")
+		if err != nil {
+			return err
+		}
+		file.Data = view
+		return nil
+	})
+	var paths []string
+	bfs.ErrorHandler(func(path string, err error) (fs.File, error) {
+		paths = append(paths, path)
+		return virtual.New(&virtual.File{
+			Path: path,
+			Data: []byte("// " + err.Error()),
+		}), nil
+	})
+	data, err := fs.ReadFile(bfs, "bud/view.go")
+	is.NoErr(err)
+	is.True(strings.Contains(string(data), "unable to parse view"))
+	// Generators see the original error
+	data, err = fs.ReadFile(bfs, "bud/main.go")
+	is.NoErr(err)
+	is.True(strings.HasPrefix(string(data), "// "))
+	is.Equal(len(paths), 2)
+	is.Equal(paths[0], "bud/view.go")
+	is.Equal(paths[1], "bud/main.go")
+	// Missing files aren't handled
+	_, err = fs.ReadFile(bfs, "bud/missing.go")
+	is.True(errors.Is(err, fs.ErrNotExist))
+	is.Equal(len(paths), 2)
+}
+
+func TestEmitFile(t *testing.T) {
+	is := is.New(t)
+	fsys := fstest.MapFS{
+		"view/index.svelte": &fstest.MapFile{Data: []byte("index")},
+		"view/about.svelte": &fstest.MapFile{Data: []byte("about")},
+	}
+	bfs := budfs.New(fsys, log.Discard)
+	bfs.GenerateDir("bud/view", func(fsys budfs.FS, dir *budfs.Dir) error {
+		views, err := fs.Glob(fsys, "view/*.svelte")
+		if err != nil {
+			return err
+		}
+		for _, view := range views {
+			name := strings.TrimSuffix(path.Base(view), ".svelte") + ".js"
+			if err := dir.EmitFile(name, []byte("compiled "+view)); err != nil {
+				return err
+			}
+		}
+		dir.GenerateFile("index.go", func(fsys budfs.FS, file *budfs.File) error {
+			file.Data = []byte("package view")
+			return nil
+		})
+		// Invalid and existing names can't be emitted
+		if err := dir.EmitFile("nested/main.js", nil); !errors.Is(err, fs.ErrInvalid) {
+			return fmt.Errorf("expected invalid error, got %v", err)
+		}
+		if err := dir.EmitFile("index.go", nil); !errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("expected exist error, got %v", err)
+		}
+		return nil
+	})
+	des, err := fs.ReadDir(bfs, "bud/view")
+	is.NoErr(err)
+	is.Equal(len(des), 3)
+	is.Equal(des[0].Name(), "about.js")
+	is.Equal(des[1].Name(), "index.go")
+	is.Equal(des[2].Name(), "index.js")
+	data, err := fs.ReadFile(bfs, "bud/view/about.js")
+	is.NoErr(err)
+	is.Equal(string(data), "compiled view/about.svelte")
+	// Emitted files change on the next run
+	delete(fsys, "view/about.svelte")
+	fsys["view/contact.svelte"] = &fstest.MapFile{Data: []byte("contact")}
+	bfs.Change("bud/view")
+	des, err = fs.ReadDir(bfs, "bud/view")
+	is.NoErr(err)
+	is.Equal(len(des), 3)
+	is.Equal(des[0].Name(), "contact.js")
+	is.Equal(des[1].Name(), "index.go")
+	is.Equal(des[2].Name(), "index.js")
+	_, err = fs.ReadFile(bfs, "bud/view/about.js")
+	is.True(errors.Is(err, fs.ErrNotExist))
+	data, err = fs.ReadFile(bfs, "bud/view/contact.js")
+	is.NoErr(err)
+	is.Equal(string(data), "compiled view/contact.svelte")
+}
+
+func TestWithContext(t *testing.T) {
+	is := is.New(t)
+	type key struct{}
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	bfs.GenerateFile("bud/view.txt", func(fsys budfs.FS, file *budfs.File) error {
+		value, _ := fsys.Context().Value(key{}).(string)
+		file.Data = []byte("view " + value)
+		return nil
+	})
+	bfs.GenerateDir("bud/controller", func(fsys budfs.FS, dir *budfs.Dir) error {
+		value, _ := fsys.Context().Value(key{}).(string)
+		dir.GenerateFile(value+".txt", func(fsys budfs.FS, file *budfs.File) error {
+			// Nested opens keep the context
+			view, err := fs.ReadFile(fsys, "bud/view.txt")
+			if err != nil {
+				return err
+			}
+			file.Data = view
+			return nil
+		})
+		return nil
+	})
+	ctx := context.WithValue(context.Background(), key{}, "bound")
+	cfs := bfs.WithContext(ctx)
+	data, err := fs.ReadFile(cfs, "bud/controller/bound.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "view bound")
+	// The original filesystem shares the cache
+	data, err = fs.ReadFile(bfs, "bud/view.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "view bound")
+	bfs.Change("bud/view.txt")
+	data, err = fs.ReadFile(bfs, "bud/view.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "view ")
+}
+
+func TestMustNew(t *testing.T) {
+	is := is.New(t)
+	type key struct{}
+	cache := vcache.New()
+	ctx := context.WithValue(context.Background(), key{}, "bound")
+	bfs := budfs.MustNew(virtual.Map{}, log.Discard, budfs.WithCache(cache), budfs.WithContext(ctx))
+	bfs.GenerateFile("bud/view.txt", func(fsys budfs.FS, file *budfs.File) error {
+		value, _ := fsys.Context().Value(key{}).(string)
+		file.Data = []byte(value)
+		return nil
+	})
+	data, err := fs.ReadFile(bfs, "bud/view.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "bound")
+	is.True(cache.Has("bud/view.txt"))
+	// Invalid options
+	_, err = budfs.NewWithOptions(virtual.Map{}, log.Discard, budfs.WithCache(nil))
+	is.True(err != nil)
+	defer func() {
+		is.True(recover() != nil)
+	}()
+	//lint:ignore SA1012 testing that a nil context panics
+	budfs.MustNew(virtual.Map{}, log.Discard, budfs.WithContext(nil))
+}
+
+func TestGenerateGoFile(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	bfs.GenerateGoFile("bud/main.go", func(fsys budfs.FS) (*ast.File, error) {
+		return &ast.File{
+			Name: ast.NewIdent("main"),
+			Decls: []ast.Decl{
+				&ast.FuncDecl{
+					Name: ast.NewIdent("main"),
+					Type: &ast.FuncType{Params: &ast.FieldList{}},
+					Body: &ast.BlockStmt{
+						List: []ast.Stmt{
+							&ast.ExprStmt{X: &ast.CallExpr{
+								Fun:  ast.NewIdent("println"),
+								Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: `"hello"`}},
+							}},
+						},
+					},
+				},
+			},
+		}, nil
+	})
+	bfs.GenerateGoFile("bud/error.go", func(fsys budfs.FS) (*ast.File, error) {
+		return nil, fmt.Errorf("unable to build syntax tree")
+	})
+	data, err := fs.ReadFile(bfs, "bud/main.go")
+	is.NoErr(err)
+	is.Equal(string(data), "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n")
+	_, err = fs.ReadFile(bfs, "bud/error.go")
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "unable to build syntax tree"))
+}
+
+func TestMarkDirty(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	viewCount, mainCount := 0, 0
+	bfs.GenerateFile("bud/view.txt", func(fsys budfs.FS, file *budfs.File) error {
+		viewCount++
+		file.Data = []byte("view")
+		return nil
+	})
+	bfs.GenerateFile("bud/main.txt", func(fsys budfs.FS, file *budfs.File) error {
+		mainCount++
+		view, err := fs.ReadFile(fsys, "bud/view.txt")
+		if err != nil {
+			return err
+		}
+		file.Data = append([]byte("main "), view...)
+		return nil
+	})
+	_, err := fs.ReadFile(bfs, "bud/main.txt")
+	is.NoErr(err)
+	is.Equal(viewCount, 1)
+	is.Equal(mainCount, 1)
+	// Only the dirty path is regenerated, not the files linked to it
+	bfs.MarkDirty("bud/view.txt")
+	_, err = fs.ReadFile(bfs, "bud/main.txt")
+	is.NoErr(err)
+	is.Equal(mainCount, 1)
+	_, err = fs.ReadFile(bfs, "bud/view.txt")
+	is.NoErr(err)
+	is.Equal(viewCount, 2)
+	// Namespaced paths are relative to the namespace
+	bfs.Namespace("bud").MarkDirty("main.txt")
+	_, err = fs.ReadFile(bfs, "bud/main.txt")
+	is.NoErr(err)
+	is.Equal(mainCount, 2)
+	is.Equal(viewCount, 2)
+}
+
+func TestGenerateLazy(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	count := 0
+	bfs.GenerateLazy("bud/index.txt", func(fsys budfs.FS, file *budfs.File) error {
+		count++
+		file.Data = []byte("index")
+		return nil
+	})
+	bfs.GenerateFile("bud/view.txt", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("view")
+		return nil
+	})
+	// Before triggering, the file doesn't exist
+	_, err := fs.ReadFile(bfs, "bud/index.txt")
+	is.True(errors.Is(err, fs.ErrNotExist))
+	des, err := fs.ReadDir(bfs, "bud")
+	is.NoErr(err)
+	is.Equal(len(des), 1)
+	is.Equal(des[0].Name(), "view.txt")
+	is.Equal(count, 0)
+	// Triggering generates the file
+	is.NoErr(bfs.Trigger("bud/index.txt"))
+	is.Equal(count, 1)
+	data, err := fs.ReadFile(bfs, "bud/index.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "index")
+	is.Equal(count, 1)
+	des, err = fs.ReadDir(bfs, "bud")
+	is.NoErr(err)
+	is.Equal(len(des), 2)
+	// Only lazy generators can be triggered
+	is.True(bfs.Trigger("bud/view.txt") != nil)
+	is.True(errors.Is(bfs.Trigger("bud/missing.txt"), fs.ErrNotExist))
+}
+
+func TestGroup(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	viewCount, mainCount := 0, 0
+	views := bfs.Group("views")
+	is.Equal(views.Name(), "views")
+	views.GenerateFile("bud/view/index.go", func(fsys budfs.FS, file *budfs.File) error {
+		viewCount++
+		file.Data = []byte("package view")
+		return nil
+	})
+	views.GenerateDir("bud/view/pages", func(fsys budfs.FS, dir *budfs.Dir) error {
+		dir.GenerateFile("index.js", func(fsys budfs.FS, file *budfs.File) error {
+			viewCount++
+			file.Data = []byte("index")
+			return nil
+		})
+		return nil
+	})
+	bfs.GenerateFile("bud/main.go", func(fsys budfs.FS, file *budfs.File) error {
+		mainCount++
+		file.Data = []byte("package main")
+		return nil
+	})
+	is.Equal(bfs.Group("views"), views)
+	is.Equal(views.Paths(), []string{"bud/view/index.go", "bud/view/pages"})
+	read := func(paths ...string) {
+		t.Helper()
+		for _, path := range paths {
+			_, err := fs.ReadFile(bfs, path)
+			is.NoErr(err)
+		}
+	}
+	read("bud/view/index.go", "bud/view/pages/index.js", "bud/main.go")
+	is.Equal(viewCount, 2)
+	is.Equal(mainCount, 1)
+	// Invalidate only the group
+	bfs.InvalidateGroup("views")
+	read("bud/view/index.go", "bud/view/pages/index.js", "bud/main.go")
+	is.Equal(viewCount, 4)
+	is.Equal(mainCount, 1)
+	// Sync only the group
+	writable := virtual.Tree{}
+	is.NoErr(bfs.SyncGroup("views", writable, "bud"))
+	data, err := fs.ReadFile(writable, "bud/view/index.go")
+	is.NoErr(err)
+	is.Equal(string(data), "package view")
+	data, err = fs.ReadFile(writable, "bud/view/pages/index.js")
+	is.NoErr(err)
+	is.Equal(string(data), "index")
+	_, err = fs.ReadFile(writable, "bud/main.go")
+	is.True(errors.Is(err, fs.ErrNotExist))
+	// Unknown groups fail to sync
+	is.True(bfs.SyncGroup("controllers", writable, "bud") != nil)
+}
+
+func TestGenerateFileSet(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{
+		"foo.txt": &virtual.File{Data: []byte("foo")},
+	}
+	bfs := budfs.New(fsys, log.Discard)
+	count := 0
+	bfs.GenerateFileSet([]string{"bud/foo.go", "bud/foo_test.go"}, func(fsys budfs.FS) (map[string][]byte, error) {
+		count++
+		source, err := fs.ReadFile(fsys, "foo.txt")
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]byte{
+			"bud/foo.go":      append([]byte("package "), source...),
+			"bud/foo_test.go": append([]byte("package "), append(source, "_test"...)...),
+		}, nil
+	})
+	bfs.GenerateFileSet([]string{"bud/bar.go", "bud/bar_test.go"}, func(fsys budfs.FS) (map[string][]byte, error) {
+		return map[string][]byte{"bud/bar.go": []byte("package bar")}, nil
+	})
+	data, err := fs.ReadFile(bfs, "bud/foo_test.go")
+	is.NoErr(err)
+	is.Equal(string(data), "package foo_test")
+	data, err = fs.ReadFile(bfs, "bud/foo.go")
+	is.NoErr(err)
+	is.Equal(string(data), "package foo")
+	is.Equal(count, 1)
+	// Changing the source regenerates the whole set
+	fsys["foo.txt"] = &virtual.File{Data: []byte("baz")}
+	bfs.Change("foo.txt")
+	data, err = fs.ReadFile(bfs, "bud/foo_test.go")
+	is.NoErr(err)
+	is.Equal(string(data), "package baz_test")
+	data, err = fs.ReadFile(bfs, "bud/foo.go")
+	is.NoErr(err)
+	is.Equal(string(data), "package baz")
+	is.Equal(count, 2)
+	// Sets must return every path
+	_, err = fs.ReadFile(bfs, "bud/bar.go")
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), `didn't return "bud/bar_test.go"`))
+}
+
+//go:embed testdata/embed
+var embedFS embed.FS
+
+func TestMountEmbed(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	bfs.GenerateFile("bud/assets/testdata/embed/service.json", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte(`{"name":"generator service"}`)
+		return nil
+	})
+	bfs.GenerateDir("bud/assets", func(fsys budfs.FS, dir *budfs.Dir) error {
+		return budfs.MountEmbed(dir, embedFS)
+	})
+	err := fstest.TestFS(bfs,
+		"bud/assets/testdata/embed/service.json",
+		"bud/assets/testdata/embed/main.css",
+		"bud/assets/testdata/embed/html/index.html",
+	)
+	is.NoErr(err)
+	data, err := fs.ReadFile(bfs, "bud/assets/testdata/embed/html/index.html")
+	is.NoErr(err)
+	is.Equal(string(data), "<h1>embed</h1>")
+	// Generators take priority over the embedded files
+	data, err = fs.ReadFile(bfs, "bud/assets/testdata/embed/service.json")
+	is.NoErr(err)
+	is.Equal(string(data), `{"name":"generator service"}`)
+}
+
+func TestServeDir(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	called := 0
+	bfs.ServeDir("bud/view", func(fsys budfs.FS, dir *budfs.Dir) error {
+		called++
+		dir.GenerateFile("index.svelte", func(fsys budfs.FS, file *budfs.File) error {
+			file.Data = []byte("<h1>index</h1>")
+			return nil
+		})
+		dir.GenerateFile("about.svelte", func(fsys budfs.FS, file *budfs.File) error {
+			file.Data = []byte("<h1>about</h1>")
+			return nil
+		})
+		return nil
+	})
+	des, err := fs.ReadDir(bfs, "bud/view")
+	is.NoErr(err)
+	is.Equal(len(des), 2)
+	is.Equal(des[0].Name(), "about.svelte")
+	is.Equal(des[1].Name(), "index.svelte")
+	is.Equal(called, 1)
+	// Listing is cached
+	des, err = fs.ReadDir(bfs, "bud/view")
+	is.NoErr(err)
+	is.Equal(len(des), 2)
+	is.Equal(called, 1)
+	data, err := fs.ReadFile(bfs, "bud/view/index.svelte")
+	is.NoErr(err)
+	is.Equal(string(data), "<h1>index</h1>")
+	is.Equal(called, 1)
+	// Changing the directory lists it again
+	bfs.Change("bud/view")
+	des, err = fs.ReadDir(bfs, "bud/view")
+	is.NoErr(err)
+	is.Equal(len(des), 2)
+	is.Equal(called, 2)
+	is.NoErr(fstest.TestFS(bfs, "bud/view/index.svelte", "bud/view/about.svelte"))
+}
+
+func TestServeDirOpenBeforeList(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	bfs.ServeDir("bud/view", func(fsys budfs.FS, dir *budfs.Dir) error {
+		dir.GenerateFile("index.svelte", func(fsys budfs.FS, file *budfs.File) error {
+			file.Data = []byte("<h1>index</h1>")
+			return nil
+		})
+		return nil
+	})
+	data, err := fs.ReadFile(bfs, "bud/view/index.svelte")
+	is.NoErr(err)
+	is.Equal(string(data), "<h1>index</h1>")
+}
+
+func TestClearCacheForDir(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	count := map[string]int{}
+	generate := func(fsys budfs.FS, file *budfs.File) error {
+		count[file.Target()]++
+		file.Data = []byte(file.Target())
+		return nil
+	}
+	bfs.GenerateFile("bud/view/index.svelte", generate)
+	bfs.GenerateFile("bud/view/about/index.svelte", generate)
+	bfs.GenerateFile("bud/viewer.go", generate)
+	for _, path := range []string{"bud/view/index.svelte", "bud/view/about/index.svelte", "bud/viewer.go"} {
+		_, err := fs.ReadFile(bfs, path)
+		is.NoErr(err)
+	}
+	is.Equal(bfs.ClearCacheForDir("bud/view"), 2)
+	is.Equal(bfs.ClearCacheForDir("bud/view"), 0)
+	for _, path := range []string{"bud/view/index.svelte", "bud/view/about/index.svelte", "bud/viewer.go"} {
+		_, err := fs.ReadFile(bfs, path)
+		is.NoErr(err)
+	}
+	is.Equal(count["bud/view/index.svelte"], 2)
+	is.Equal(count["bud/view/about/index.svelte"], 2)
+	is.Equal(count["bud/viewer.go"], 1)
+	is.Equal(bfs.ClearCacheForDir("."), 3)
+}
+
+func TestMovePath(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{
+		"view/index.svelte": &virtual.File{Data: []byte("<h1>index</h1>")},
+	}
+	bfs := budfs.New(fsys, log.Discard)
+	count := 0
+	bfs.GenerateFile("bud/view/index.go", func(fsys budfs.FS, file *budfs.File) error {
+		count++
+		data, err := fs.ReadFile(fsys, "view/index.svelte")
+		if err != nil {
+			return err
+		}
+		file.Data = data
+		return nil
+	})
+	bfs.GenerateFile("bud/app.go", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("app")
+		return nil
+	})
+	data, err := fs.ReadFile(bfs, "bud/view/index.go")
+	is.NoErr(err)
+	is.Equal(string(data), "<h1>index</h1>")
+	is.Equal(count, 1)
+	is.NoErr(bfs.MovePath("bud/view/index.go", "bud/page/index.go"))
+	// The cached file moved with the generator
+	data, err = fs.ReadFile(bfs, "bud/page/index.go")
+	is.NoErr(err)
+	is.Equal(string(data), "<h1>index</h1>")
+	is.Equal(count, 1)
+	_, err = fs.Stat(bfs, "bud/view/index.go")
+	is.True(errors.Is(err, fs.ErrNotExist))
+	// Links moved with the generator
+	bfs.Change("view/index.svelte")
+	data, err = fs.ReadFile(bfs, "bud/page/index.go")
+	is.NoErr(err)
+	is.Equal(string(data), "<h1>index</h1>")
+	is.Equal(count, 2)
+	// Missing source
+	err = bfs.MovePath("bud/view/index.go", "bud/view.go")
+	is.True(errors.Is(err, fs.ErrNotExist))
+	// Existing destination
+	err = bfs.MovePath("bud/page/index.go", "bud/app.go")
+	is.True(errors.Is(err, fs.ErrExist))
+}
+
+func TestMovePathGenerators(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	count := 0
+	bfs.GenerateFileSet([]string{"bud/a.txt", "bud/b.txt"}, func(fsys budfs.FS) (map[string][]byte, error) {
+		count++
+		return map[string][]byte{"bud/a.txt": []byte("a"), "bud/b.txt": []byte("b")}, nil
+	})
+	bfs.Alias("bud/alias.txt", "bud/a.txt")
+	is.NoErr(bfs.Mount("bud/public", fstest.MapFS{
+		"favicon.ico": &fstest.MapFile{Data: []byte("favicon")},
+	}))
+	bfs.GenerateDir("bud/view", func(fsys budfs.FS, dir *budfs.Dir) error {
+		return dir.EmitFile("index.svelte", []byte("<h1>index</h1>"))
+	})
+	data, err := fs.ReadFile(bfs, "bud/view/index.svelte")
+	is.NoErr(err)
+	is.Equal(string(data), "<h1>index</h1>")
+	// Move a file from the set
+	is.NoErr(bfs.MovePath("bud/a.txt", "bud/set/a.txt"))
+	data, err = fs.ReadFile(bfs, "bud/set/a.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "a")
+	data, err = fs.ReadFile(bfs, "bud/b.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "b")
+	is.Equal(count, 1)
+	// Evicting the moved file regenerates the set
+	bfs.Change("bud/set/a.txt")
+	data, err = fs.ReadFile(bfs, "bud/set/a.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "a")
+	is.Equal(count, 2)
+	// The alias follows the file it points to
+	data, err = fs.ReadFile(bfs, "bud/alias.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "a")
+	is.Equal(bfs.Aliases()["bud/alias.txt"], "bud/set/a.txt")
+	// Move the mount
+	is.NoErr(bfs.MovePath("bud/public", "bud/static"))
+	data, err = fs.ReadFile(bfs, "bud/static/favicon.ico")
+	is.NoErr(err)
+	is.Equal(string(data), "favicon")
+	// Files can't be moved out of a directory mount
+	bfs.GenerateDir("bud/assets", func(fsys budfs.FS, dir *budfs.Dir) error {
+		return dir.Mount(fstest.MapFS{
+			"logo.svg": &fstest.MapFile{Data: []byte("<svg/>")},
+		})
+	})
+	data, err = fs.ReadFile(bfs, "bud/assets/logo.svg")
+	is.NoErr(err)
+	is.Equal(string(data), "<svg/>")
+	err = bfs.MovePath("bud/assets/logo.svg", "bud/logo.svg")
+	is.True(errors.Is(err, fs.ErrInvalid))
+	// Move a directory with emitted files
+	is.NoErr(bfs.MovePath("bud/view", "bud/page"))
+	data, err = fs.ReadFile(bfs, "bud/page/index.svelte")
+	is.NoErr(err)
+	is.Equal(string(data), "<h1>index</h1>")
+	des, err := fs.ReadDir(bfs, "bud/page")
+	is.NoErr(err)
+	is.Equal(len(des), 1)
+	is.Equal(des[0].Name(), "index.svelte")
+}
+
+func TestCompact(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{
+		"view/index.svelte": &virtual.File{Data: []byte("<h1>index</h1>")},
+	}
+	cache := vcache.New()
+	bfs := budfs.New(fsys, log.Discard).WithSharedCache(cache)
+	bfs.GenerateFile("bud/view/index.go", func(fsys budfs.FS, file *budfs.File) error {
+		data, err := fs.ReadFile(fsys, "view/index.svelte")
+		if err != nil {
+			return err
+		}
+		file.Data = data
+		return nil
+	})
+	_, err := fs.ReadFile(bfs, "bud/view/index.go")
+	is.NoErr(err)
+	is.NoErr(bfs.MovePath("bud/view/index.go", "bud/page/index.go"))
+	// Orphaned cache entry
+	cache.Set("bud/orphan.go", &virtual.File{Path: "bud/orphan.go"})
+	is.NoErr(bfs.Compact())
+	is.True(!cache.Has("bud/orphan.go"))
+	is.True(cache.Has("bud/page/index.go"))
+	// The empty directory was removed
+	des, err := fs.ReadDir(bfs, "bud")
+	is.NoErr(err)
+	is.Equal(len(des), 1)
+	is.Equal(des[0].Name(), "page")
+	// Links still work after compacting
+	bfs.Change("view/index.svelte")
+	is.True(!cache.Has("bud/page/index.go"))
+	data, err := fs.ReadFile(bfs, "bud/page/index.go")
+	is.NoErr(err)
+	is.Equal(string(data), "<h1>index</h1>")
+}
+
+func TestFreeze(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	bfs.GenerateDir("bud/view", func(fsys budfs.FS, dir *budfs.Dir) error {
+		// Directories can still register generators within themselves
+		dir.GenerateFile("index.go", func(fsys budfs.FS, file *budfs.File) error {
+			file.Data = []byte("package view")
+			return nil
+		})
+		return nil
+	})
+	is.True(!bfs.Frozen())
+	bfs.Freeze()
+	is.True(bfs.Frozen())
+	data, err := fs.ReadFile(bfs, "bud/view/index.go")
+	is.NoErr(err)
+	is.Equal(string(data), "package view")
+	panics := func(fn func()) (message string) {
+		defer func() {
+			message = fmt.Sprint(recover())
+		}()
+		fn()
+		return ""
+	}
+	message := panics(func() {
+		bfs.GenerateFile("bud/main.go", func(fsys budfs.FS, file *budfs.File) error {
+			return nil
+		})
+	})
+	is.True(strings.Contains(message, `"bud/main.go"`))
+	is.True(strings.Contains(message, "frozen"))
+	message = panics(func() {
+		bfs.ServeFile("bud/public", func(fsys budfs.FS, file *budfs.File) error {
+			return nil
+		})
+	})
+	is.True(strings.Contains(message, "frozen"))
+	message = panics(func() {
+		bfs.Mount("bud/node_modules", virtual.Map{})
+	})
+	is.True(strings.Contains(message, "frozen"))
+	message = panics(func() {
+		bfs.OpenDir("bud/controller")
+	})
+	is.True(strings.Contains(message, `"bud/controller"`))
+	is.True(strings.Contains(message, "frozen"))
+	// Namespaces share the frozen state
+	message = panics(func() {
+		bfs.Namespace("bud").GenerateDir("view", func(fsys budfs.FS, dir *budfs.Dir) error {
+			return nil
+		})
+	})
+	is.True(strings.Contains(message, `"bud/view"`))
+}
+
+func TestWithOpener(t *testing.T) {
+	is := is.New(t)
+	var entries []log.Entry
+	handler := log.New(handlerFunc(func(entry log.Entry) {
+		if entry.Message == "budfs: open" {
+			entries = append(entries, entry)
+		}
+	}))
+	bfs := budfs.New(virtual.Map{}, handler)
+	bfs.GenerateFile("bud/view.go", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("package view")
+		return nil
+	})
+	traced := bfs.WithOpener(func(name string) (string, map[string]string) {
+		return "trace:" + name, map[string]string{"trace_id": "abc", "request_id": "123"}
+	})
+	// The original name is still opened
+	data, err := fs.ReadFile(traced, "bud/view.go")
+	is.NoErr(err)
+	is.Equal(string(data), "package view")
+	is.Equal(len(entries), 1)
+	is.Equal(entries[0].Fields, []log.Field{
+		{Key: "path", Value: "trace:bud/view.go"},
+		{Key: "request_id", Value: "123"},
+		{Key: "trace_id", Value: "abc"},
+	})
+	// The original filesystem isn't affected
+	_, err = fs.ReadFile(bfs, "bud/view.go")
+	is.NoErr(err)
+	is.Equal(len(entries), 1)
+}
+
+func TestGenerate(t *testing.T) {
+	is := is.New(t)
+	cache := vcache.New()
+	bfs := budfs.New(virtual.Map{}, log.Discard).WithSharedCache(cache)
+	var count int32
+	for _, path := range []string{"bud/a.go", "bud/b.go", "bud/c.go"} {
+		bfs.GenerateFile(path, func(fsys budfs.FS, file *budfs.File) error {
+			atomic.AddInt32(&count, 1)
+			file.Data = []byte(file.Target())
+			return nil
+		})
+	}
+	bfs.GenerateFile("bud/error.go", func(fsys budfs.FS, file *budfs.File) error {
+		return errors.New("unable to generate")
+	})
+	bfs.ErrorHandler(func(path string, err error) (fs.File, error) {
+		return virtual.New(&virtual.File{Path: path}), nil
+	})
+	err := bfs.Generate(context.Background(), "bud/a.go", "bud/b.go", "bud/c.go")
+	is.NoErr(err)
+	is.Equal(atomic.LoadInt32(&count), int32(3))
+	is.True(cache.Has("bud/a.go"))
+	is.True(cache.Has("bud/b.go"))
+	is.True(cache.Has("bud/c.go"))
+	// Errors aren't passed to the error handler
+	err = bfs.Generate(context.Background(), "bud/a.go", "bud/error.go")
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "unable to generate"))
+	is.Equal(atomic.LoadInt32(&count), int32(3))
+	// Canceled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = bfs.Generate(ctx, "bud/a.go")
+	is.True(errors.Is(err, context.Canceled))
+}
+
+func TestOpenWithFallback(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{}
+	defaults := virtual.Map{
+		"view/layout.svelte": &virtual.File{Data: []byte("<slot />")},
+		"view/error.svelte":  &virtual.File{Data: []byte("default error")},
+	}
+	bfs := budfs.New(fsys, log.Discard)
+	count := 0
+	bfs.GenerateFile("bud/layout.svelte", func(fsys budfs.FS, file *budfs.File) error {
+		count++
+		f, err := fsys.OpenWithFallback("view/layout.svelte", defaults)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		file.Data = data
+		return nil
+	})
+	data, err := fs.ReadFile(bfs, "bud/layout.svelte")
+	is.NoErr(err)
+	is.Equal(string(data), "<slot />")
+	is.Equal(count, 1)
+	// Creating the file overrides the fallback
+	fsys["view/layout.svelte"] = &virtual.File{Data: []byte("<main><slot /></main>")}
+	bfs.Change("view/layout.svelte")
+	data, err = fs.ReadFile(bfs, "bud/layout.svelte")
+	is.NoErr(err)
+	is.Equal(string(data), "<main><slot /></main>")
+	is.Equal(count, 2)
+	// Opening from the filesystem directly
+	file, err := bfs.OpenWithFallback("view/error.svelte", virtual.Map{}, defaults)
+	is.NoErr(err)
+	data, err = io.ReadAll(file)
+	is.NoErr(err)
+	is.NoErr(file.Close())
+	is.Equal(string(data), "default error")
+	// Missing from every filesystem
+	_, err = bfs.OpenWithFallback("view/missing.svelte", defaults)
+	is.True(errors.Is(err, fs.ErrNotExist))
+}
+
+type notifierFunc func(events []budfs.ChangeEvent) error
+
+func (fn notifierFunc) Notify(events []budfs.ChangeEvent) error {
+	return fn(events)
+}
+
+func TestNotifier(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{
+		"view/index.svelte": &virtual.File{Data: []byte("<h1>index</h1>")},
+	}
+	bfs := budfs.New(fsys, log.Discard)
+	var notified [][]budfs.ChangeEvent
+	bfs.SetNotifier(notifierFunc(func(events []budfs.ChangeEvent) error {
+		notified = append(notified, events)
+		return nil
+	}))
+	bfs.GenerateFile("bud/view/index.go", func(fsys budfs.FS, file *budfs.File) error {
+		data, err := fs.ReadFile(fsys, "view/index.svelte")
+		if err != nil {
+			return err
+		}
+		file.Data = data
+		return nil
+	})
+	bfs.GenerateFile("bud/main.go", func(fsys budfs.FS, file *budfs.File) error {
+		data, err := fs.ReadFile(fsys, "bud/view/index.go")
+		if err != nil {
+			return err
+		}
+		file.Data = data
+		return nil
+	})
+	_, err := fs.ReadFile(bfs, "bud/main.go")
+	is.NoErr(err)
+	bfs.Change("view/index.svelte")
+	is.Equal(len(notified), 1)
+	is.Equal(notified[0], []budfs.ChangeEvent{
+		{Path: "bud/view/index.go", Cause: "view/index.svelte"},
+		{Path: "bud/main.go", Cause: "bud/view/index.go"},
+	})
+	// Nothing was invalidated
+	bfs.Change("view/index.svelte")
+	is.Equal(len(notified), 1)
+	// Notifier errors are logged
+	bfs.SetNotifier(notifierFunc(func(events []budfs.ChangeEvent) error {
+		return errors.New("unable to notify")
+	}))
+	_, err = fs.ReadFile(bfs, "bud/main.go")
+	is.NoErr(err)
+	bfs.Change("bud/main.go")
+}
+
+func TestCacheInspector(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	bfs.GenerateFile("bud/a.txt", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("aaa")
+		return nil
+	})
+	bfs.GenerateFile("bud/b.txt", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("b")
+		return nil
+	})
+	inspector := bfs.CacheInspector()
+	is.Equal(inspector.TotalBytes(), int64(0))
+	is.Equal(len(inspector.Entries()), 0)
+	for i := 0; i < 3; i++ {
+		_, err := fs.ReadFile(bfs, "bud/a.txt")
+		is.NoErr(err)
+	}
+	_, err := fs.ReadFile(bfs, "bud/b.txt")
+	is.NoErr(err)
+	is.Equal(inspector.TotalBytes(), int64(4))
+	entries := inspector.Entries()
+	is.Equal(len(entries), 2)
+	is.Equal(entries[0].Path, "bud/a.txt")
+	is.Equal(entries[0].Size, int64(3))
+	is.True(entries[0].Hits > 0)
+	is.True(!entries[0].LastAccess.IsZero())
+	is.Equal(entries[1].Path, "bud/b.txt")
+	is.Equal(entries[1].Size, int64(1))
+	is.True(entries[0].Hits > entries[1].Hits)
+	is.True(inspector.HitRate() > 0 && inspector.HitRate() < 1)
+	// Shared caches without usage tracking
+	bfs = budfs.New(virtual.Map{}, log.Discard).WithSharedCache(vcache.New())
+	bfs.GenerateFile("bud/a.txt", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("aaa")
+		return nil
+	})
+	_, err = fs.ReadFile(bfs, "bud/a.txt")
+	is.NoErr(err)
+	inspector = bfs.CacheInspector()
+	is.Equal(inspector.Entries(), []budfs.CacheEntry{{Path: "bud/a.txt", Size: 3}})
+	is.Equal(inspector.HitRate(), float64(0))
+	is.Equal(inspector.TotalBytes(), int64(3))
+}
+
+func TestOpenDir(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	bfs.GenerateFile("bud/main.go", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("package main")
+		return nil
+	})
+	dir, err := bfs.OpenDir("bud/view")
+	is.NoErr(err)
+	is.Equal(dir.Path(), "bud/view")
+	dir.GenerateFile("index.go", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("package view")
+		return nil
+	})
+	data, err := fs.ReadFile(bfs, "bud/view/index.go")
+	is.NoErr(err)
+	is.Equal(string(data), "package view")
+	is.NoErr(fstest.TestFS(bfs, "bud/main.go", "bud/view/index.go"))
+	// Opening an existing directory
+	dir, err = bfs.OpenDir("bud")
+	is.NoErr(err)
+	is.Equal(dir.Path(), "bud")
+	// Files aren't directories
+	_, err = bfs.OpenDir("bud/main.go")
+	is.True(err != nil)
+	_, err = bfs.OpenDir("bud/main.go/view")
+	is.True(err != nil)
+	_, err = bfs.OpenDir("../bud")
+	is.True(errors.Is(err, fs.ErrInvalid))
+	// Emitting files isn't supported outside GenerateDir
+	is.True(dir.EmitFile("emitted.txt", nil) != nil)
+}
+
+func TestListGenerators(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	bfs.GenerateFile("bud/main.go", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("package main")
+		return nil
+	})
+	bfs.GenerateDir("bud/view", func(fsys budfs.FS, dir *budfs.Dir) error {
+		return nil
+	})
+	bfs.ServeFile("bud/public", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte(file.Target())
+		return nil
+	})
+	bfs.GenerateFile("bud/a.go", func(fsys budfs.FS, file *budfs.File) error {
+		return nil
+	})
+	is.Equal(bfs.ListGenerators(), []budfs.GeneratorInfo{
+		{Path: "bud/a.go", Kind: "file", TypeName: "*budfs.fileGenerator", CacheState: "cold"},
+		{Path: "bud/main.go", Kind: "file", TypeName: "*budfs.fileGenerator", CacheState: "cold"},
+		{Path: "bud/public", Kind: "server", TypeName: "*budfs.fileServer", CacheState: "cold"},
+		{Path: "bud/view", Kind: "dir", TypeName: "*budfs.dirGenerator", CacheState: "cold"},
+	})
+	_, err := fs.ReadFile(bfs, "bud/main.go")
+	is.NoErr(err)
+	_, err = fs.ReadDir(bfs, "bud/view")
+	is.NoErr(err)
+	_, err = fs.ReadFile(bfs, "bud/public/app.css")
+	is.NoErr(err)
+	for _, info := range bfs.ListGenerators() {
+		if info.Path == "bud/a.go" {
+			is.Equal(info.CacheState, "cold")
+			continue
+		}
+		is.Equal(info.CacheState, "warm")
+	}
+}
+
+func TestReadDirConcurrentGenerateDir(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	bfs.GenerateDir("bud/view", func(fsys budfs.FS, dir *budfs.Dir) error {
+		for i := 0; i < 10; i++ {
+			dir.GenerateFile(fmt.Sprintf("%d.txt", i), func(fsys budfs.FS, file *budfs.File) error {
+				file.Data = []byte(file.Target())
+				return nil
+			})
+		}
+		return nil
+	})
+	eg := new(errgroup.Group)
+	for i := 0; i < 20; i++ {
+		eg.Go(func() error {
+			des, err := fs.ReadDir(bfs, "bud/view")
+			if err != nil {
+				return err
+			} else if len(des) != 10 {
+				return fmt.Errorf("expected 10 entries, got %d", len(des))
+			}
+			return nil
+		})
+		eg.Go(func() error {
+			bfs.Change("bud/view")
+			return nil
+		})
+	}
+	is.NoErr(eg.Wait())
+}
+
+func TestTransactionLog(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{
+		"view/index.svelte": &virtual.File{Data: []byte("<h1>index</h1>")},
+	}
+	bfs := budfs.New(fsys, log.Discard)
+	bfs.GenerateFile("bud/view.go", func(fsys budfs.FS, file *budfs.File) error {
+		data, err := fs.ReadFile(fsys, "view/index.svelte")
+		if err != nil {
+			return err
+		}
+		file.Data = data
+		return nil
+	})
+	// Nothing is recorded until the log is enabled
+	_, err := fs.ReadFile(bfs, "bud/view.go")
+	is.NoErr(err)
+	is.Equal(len(bfs.TransactionLog()), 0)
+	bfs.EnableTransactionLog()
+	bfs.Change("view/index.svelte")
+	_, err = fs.ReadFile(bfs, "bud/view.go")
+	is.NoErr(err)
+	_, err = fs.ReadFile(bfs, "bud/view.go")
+	is.NoErr(err)
+	var ops []string
+	for _, entry := range bfs.TransactionLog() {
+		is.True(!entry.Time.IsZero())
+		ops = append(ops, entry.Op+":"+entry.Path)
+	}
+	is.Equal(ops, []string{
+		"change:view/index.svelte",
+		"cache_miss:bud/view.go",
+		"generate:bud/view.go",
+		"cache_hit:bud/view.go",
+	})
+	// Only the most recent entries are kept
+	bfs.SetTransactionLogSize(2)
+	entries := bfs.TransactionLog()
+	is.Equal(len(entries), 2)
+	is.Equal(entries[0].Op, "generate")
+	is.Equal(entries[1].Op, "cache_hit")
+	for i := 0; i < 3; i++ {
+		bfs.Change(fmt.Sprintf("%d.txt", i))
+	}
+	entries = bfs.TransactionLog()
+	is.Equal(len(entries), 2)
+	is.Equal(entries[0].Path, "1.txt")
+	is.Equal(entries[1].Path, "2.txt")
+}
+
+func TestAsyncChange(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	var count int32
+	bfs.GenerateFile("bud/view.go", func(fsys budfs.FS, file *budfs.File) error {
+		atomic.AddInt32(&count, 1)
+		file.Data = []byte("package view")
+		return nil
+	})
+	_, err := fs.ReadFile(bfs, "bud/view.go")
+	is.NoErr(err)
+	<-bfs.AsyncChange("bud/view.go")
+	_, err = fs.ReadFile(bfs, "bud/view.go")
+	is.NoErr(err)
+	is.Equal(atomic.LoadInt32(&count), int32(2))
+	// Rapid changes are coalesced and every channel closes
+	var dones []<-chan struct{}
+	for i := 0; i < 100; i++ {
+		dones = append(dones, bfs.AsyncChange("bud/view.go"))
+	}
+	for _, done := range dones {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the change")
+		}
+	}
+	_, err = fs.ReadFile(bfs, "bud/view.go")
+	is.NoErr(err)
+	is.Equal(atomic.LoadInt32(&count), int32(3))
+	// Namespaced changes
+	<-bfs.Namespace("bud").AsyncChange("view.go")
+	_, err = fs.ReadFile(bfs, "bud/view.go")
+	is.NoErr(err)
+	is.Equal(atomic.LoadInt32(&count), int32(4))
+}
+
+func TestSubscribe(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{
+		"view/index.svelte": &virtual.File{Data: []byte("<h1>index</h1>")},
+	}
+	bfs := budfs.New(fsys, log.Discard)
+	bfs.GenerateFile("bud/view.go", func(fsys budfs.FS, file *budfs.File) error {
+		data, err := fs.ReadFile(fsys, "view/index.svelte")
+		if err != nil {
+			return err
+		}
+		file.Data = data
+		return nil
+	})
+	var first, second [][]string
+	cancel := bfs.Subscribe(func(paths []string) {
+		first = append(first, paths)
+	})
+	bfs.Subscribe(func(paths []string) {
+		second = append(second, paths)
+	})
+	_, err := fs.ReadFile(bfs, "bud/view.go")
+	is.NoErr(err)
+	bfs.Change("view/index.svelte")
+	is.Equal(first, [][]string{{"bud/view.go"}})
+	is.Equal(second, [][]string{{"bud/view.go"}})
+	// Nothing was removed from the cache
+	bfs.Change("view/index.svelte")
+	is.Equal(len(first), 1)
+	// Unsubscribe the first subscriber
+	cancel()
+	_, err = fs.ReadFile(bfs, "bud/view.go")
+	is.NoErr(err)
+	bfs.Change("bud/view.go")
+	is.Equal(len(first), 1)
+	is.Equal(second, [][]string{{"bud/view.go"}, {"bud/view.go"}})
+}
+
+func TestReadDirPage(t *testing.T) {
+	is := is.New(t)
+	bfs := budfs.New(virtual.Map{}, log.Discard)
+	for _, name := range []string{"a.go", "b.go", "c.go", "d.go", "e.go"} {
+		bfs.GenerateFile("bud/view/"+name, func(fsys budfs.FS, file *budfs.File) error {
+			return nil
+		})
+	}
+	names := func(des []fs.DirEntry) (names []string) {
+		for _, de := range des {
+			names = append(names, de.Name())
+		}
+		return names
+	}
+	des, err := bfs.ReadDirPage("bud/view", "", 2)
+	is.NoErr(err)
+	is.Equal(names(des), []string{"a.go", "b.go"})
+	des, err = bfs.ReadDirPage("bud/view", "b.go", 2)
+	is.NoErr(err)
+	is.Equal(names(des), []string{"c.go", "d.go"})
+	// Paths work as cursors too
+	des, err = bfs.ReadDirPage("bud/view", "bud/view/d.go", 2)
+	is.NoErr(err)
+	is.Equal(names(des), []string{"e.go"})
+	des, err = bfs.ReadDirPage("bud/view", "e.go", 2)
+	is.NoErr(err)
+	is.Equal(len(des), 0)
+	// Cursors don't need to exist
+	des, err = bfs.ReadDirPage("bud/view", "bb.go", 0)
+	is.NoErr(err)
+	is.Equal(names(des), []string{"c.go", "d.go", "e.go"})
+	// Custom orders
+	bfs.Reorder(func(a, b string) bool { return a > b })
+	des, err = bfs.ReadDirPage("bud/view", "d.go", 2)
+	is.NoErr(err)
+	is.Equal(names(des), []string{"c.go", "b.go"})
+	_, err = bfs.ReadDirPage("bud/missing", "", 2)
+	is.True(errors.Is(err, fs.ErrNotExist))
+}
+
+func TestGenerateDirFromMap(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{}
+	log := testlog.New()
+	bfs := budfs.New(fsys, log)
+	bfs.GenerateDirFromMap("bud/view", map[string]budfs.FileGenerator{
+		"tailwind.css":     &tailwind{},
+		"index.svelte":     &svelte{},
+		"about/index.html": &commandGenerator{Input: "a"},
+	})
+	des, err := fs.ReadDir(bfs, "bud/view")
+	is.NoErr(err)
+	is.Equal(len(des), 3)
+	is.Equal(des[0].Name(), "about")
+	is.Equal(des[1].Name(), "index.svelte")
+	is.Equal(des[2].Name(), "tailwind.css")
+	code, err := fs.ReadFile(bfs, "bud/view/index.svelte")
+	is.NoErr(err)
+	is.Equal(string(code), "/* svelte */")
+	code, err = fs.ReadFile(bfs, "bud/view/tailwind.css")
+	is.NoErr(err)
+	is.Equal(string(code), "/* tailwind */")
+	code, err = fs.ReadFile(bfs, "bud/view/about/index.html")
+	is.NoErr(err)
+	is.Equal(string(code), "aa")
+}
+
+func TestOverrideFile(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{}
+	log := testlog.New()
+	bfs := budfs.New(fsys, log)
+	bfs.GenerateFile("bud/view/index.svelte", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("/* svelte */")
+		return nil
+	})
+	bfs.OverrideFile("bud/view/index.svelte", []byte("/* override */"))
+	bfs.OverrideFile("bud/view/about.svelte", []byte("/* about */"))
+	code, err := fs.ReadFile(bfs, "bud/view/index.svelte")
+	is.NoErr(err)
+	is.Equal(string(code), "/* override */")
+	code, err = fs.ReadFile(bfs, "bud/view/about.svelte")
+	is.NoErr(err)
+	is.Equal(string(code), "/* about */")
+	is.Equal(bfs.GenerateCount(), int64(0))
+	// Overrides survive changes
+	bfs.Change("bud/view/index.svelte")
+	code, err = fs.ReadFile(bfs, "bud/view/index.svelte")
+	is.NoErr(err)
+	is.Equal(string(code), "/* override */")
+	// Clearing the override runs the generator again
+	bfs.ClearOverride("bud/view/index.svelte")
+	code, err = fs.ReadFile(bfs, "bud/view/index.svelte")
+	is.NoErr(err)
+	is.Equal(string(code), "/* svelte */")
+	is.Equal(bfs.GenerateCount(), int64(1))
+	bfs.ClearOverride("bud/view/about.svelte")
+	code, err = fs.ReadFile(bfs, "bud/view/about.svelte")
+	is.True(errors.Is(err, fs.ErrNotExist))
+	is.Equal(code, nil)
+}
+
+func TestInterceptPrefix(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{}
+	log := testlog.New()
+	bfs := budfs.New(fsys, log)
+	bfs.GenerateFile("bud/view/index.svelte", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("/* index */")
+		return nil
+	})
+	bfs.GenerateFile("bud/view/about.svelte", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("/* about */")
+		return nil
+	})
+	bfs.GenerateFile("bud/viewer/viewer.go", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("package viewer")
+		return nil
+	})
+	var paths []string
+	bfs.InterceptPrefix("bud/view", func(path string) ([]byte, bool) {
+		paths = append(paths, path)
+		if path == "bud/view/index.svelte" {
+			return []byte("/* mock */"), true
+		}
+		return nil, false
+	})
+	code, err := fs.ReadFile(bfs, "bud/view/index.svelte")
+	is.NoErr(err)
+	is.Equal(string(code), "/* mock */")
+	code, err = fs.ReadFile(bfs, "bud/view/about.svelte")
+	is.NoErr(err)
+	is.Equal(string(code), "/* about */")
+	code, err = fs.ReadFile(bfs, "bud/viewer/viewer.go")
+	is.NoErr(err)
+	is.Equal(string(code), "package viewer")
+	is.Equal(len(paths), 2)
+	is.Equal(paths[0], "bud/view/index.svelte")
+	is.Equal(paths[1], "bud/view/about.svelte")
+	is.Equal(bfs.GenerateCount(), int64(2))
+}
+
+func TestSetClock(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{}
+	log := testlog.New()
+	bfs := budfs.New(fsys, log)
+	bfs.SetClock(budfstest.FakeClock())
+	bfs.EnableTransactionLog()
+	bfs.GenerateFile("bud/view/index.svelte", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("/* svelte */")
+		return nil
+	})
+	bfs.GenerateFileSet([]string{"bud/a.txt", "bud/b.txt"}, func(fsys budfs.FS) (map[string][]byte, error) {
+		return map[string][]byte{"bud/a.txt": []byte("a"), "bud/b.txt": []byte("b")}, nil
+	})
+	for _, path := range []string{"bud/view/index.svelte", "bud/a.txt", "bud/b.txt"} {
+		stat, err := fs.Stat(bfs, path)
+		is.NoErr(err)
+		is.Equal(stat.ModTime(), budfstest.FakeTime)
+	}
+	entries := bfs.TransactionLog()
+	is.True(len(entries) > 0)
+	for _, entry := range entries {
+		is.Equal(entry.Time, budfstest.FakeTime)
+	}
+	// Cache entries are stamped with the clock too
+	cached := bfs.CacheInspector().Entries()
+	is.True(len(cached) > 0)
+	for _, entry := range cached {
+		is.Equal(entry.LastAccess, budfstest.FakeTime)
+	}
+}
+
+func TestMustGenerate(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	fsys := virtual.Map{}
+	log := testlog.New()
+	bfs := budfs.New(fsys, log)
+	bfs.GenerateFile("bud/view/index.svelte", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("/* svelte */")
+		return nil
+	})
+	bfs.GenerateFile("bud/view/error.svelte", func(fsys budfs.FS, file *budfs.File) error {
+		return fmt.Errorf("unable to compile")
+	})
+	is.Equal(string(bfs.MustGenerate(ctx, "bud/view/index.svelte")), "/* svelte */")
+	defer func() {
+		err, ok := recover().(error)
+		is.True(ok)
+		is.True(strings.Contains(err.Error(), `"bud/view/error.svelte"`))
+		is.True(strings.Contains(err.Error(), "unable to compile"))
+	}()
+	bfs.MustGenerate(ctx, "bud/view/error.svelte")
+	t.Fatal("expected MustGenerate to panic")
+}
+
+func TestCheck(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	fsys := virtual.Map{}
+	log := testlog.New()
+	bfs := budfs.New(fsys, log)
+	bfs.GenerateFile("bud/view/index.svelte", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("/* svelte */")
+		return nil
+	})
+	bfs.GenerateDir("bud/controller", func(fsys budfs.FS, dir *budfs.Dir) error {
+		dir.GenerateFile("controller.go", func(fsys budfs.FS, file *budfs.File) error {
+			return fmt.Errorf("unable to parse controller")
+		})
+		dir.GenerateFile("users/controller.go", func(fsys budfs.FS, file *budfs.File) error {
+			file.Data = []byte("package users")
+			return nil
+		})
+		return nil
+	})
+	bfs.ServeFile("bud/public", func(fsys budfs.FS, file *budfs.File) error {
+		return fmt.Errorf("servers shouldn't be checked")
+	})
+	// Warm the cache before checking
+	code, err := fs.ReadFile(bfs, "bud/view/index.svelte")
+	is.NoErr(err)
+	is.Equal(string(code), "/* svelte */")
+	before := bfs.CacheInspector().Entries()
+	bfs.ResetCounters()
+	err = bfs.Check(ctx)
+	is.True(err != nil)
+	var errs budfs.MultiError
+	is.True(errors.As(err, &errs))
+	is.Equal(len(errs), 1)
+	is.True(strings.Contains(err.Error(), `"bud/controller/controller.go"`))
+	is.True(strings.Contains(err.Error(), "unable to parse controller"))
+	// Every generator ran, even though the index was cached
+	is.Equal(bfs.GenerateCount(), int64(4))
+	// The cache is unchanged
+	after := bfs.CacheInspector().Entries()
+	is.Equal(len(after), len(before))
+	for i := range before {
+		is.Equal(after[i].Path, before[i].Path)
+	}
+	bfs.ResetCounters()
+	code, err = fs.ReadFile(bfs, "bud/view/index.svelte")
+	is.NoErr(err)
+	is.Equal(string(code), "/* svelte */")
+	is.Equal(bfs.GenerateCount(), int64(0))
+	code, err = fs.ReadFile(bfs, "bud/controller/users/controller.go")
+	is.NoErr(err)
+	is.Equal(string(code), "package users")
+	is.Equal(bfs.GenerateCount(), int64(1))
+}
+
+func TestCheckOK(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	fsys := virtual.Map{}
+	log := testlog.New()
+	bfs := budfs.New(fsys, log)
+	bfs.GenerateDir("bud/view", view())
+	is.NoErr(bfs.Check(ctx))
+	// Nothing is left in the cache
+	is.Equal(len(bfs.CacheInspector().Entries()), 0)
+}
+
+func TestReplaceFile(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{}
+	log := testlog.New()
+	bfs := budfs.New(fsys, log)
+	bfs.GenerateFile("bud/view/index.svelte", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("/* v1 */")
+		return nil
+	})
+	bfs.GenerateFile("bud/view/index.js", func(fsys budfs.FS, file *budfs.File) error {
+		code, err := fs.ReadFile(fsys, "bud/view/index.svelte")
+		if err != nil {
+			return err
+		}
+		file.Data = []byte("// " + string(code))
+		return nil
+	})
+	code, err := fs.ReadFile(bfs, "bud/view/index.js")
+	is.NoErr(err)
+	is.Equal(string(code), "// /* v1 */")
+	err = bfs.ReplaceFile("bud/view/index.svelte", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("/* v2 */")
+		return nil
+	})
+	is.NoErr(err)
+	code, err = fs.ReadFile(bfs, "bud/view/index.svelte")
+	is.NoErr(err)
+	is.Equal(string(code), "/* v2 */")
+	// Files generated from the replaced file are regenerated too
+	code, err = fs.ReadFile(bfs, "bud/view/index.js")
+	is.NoErr(err)
+	is.Equal(string(code), "// /* v2 */")
+	// Replacing a missing generator fails
+	err = bfs.ReplaceFile("bud/view/about.svelte", func(fsys budfs.FS, file *budfs.File) error {
+		return nil
+	})
+	is.True(errors.Is(err, fs.ErrNotExist))
+	err = bfs.ReplaceFile("bud/view", func(fsys budfs.FS, file *budfs.File) error {
+		return nil
+	})
+	is.True(errors.Is(err, fs.ErrNotExist))
+}
+
+func TestGeneratorInput(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	bfs := budfs.New(virtual.Map{}, testlog.New())
+	fsys, file := geninput.New(bfs, ctx, "fuzz", "fuzz/../a//b")
+	is.Equal(fsys.(budfs.FS).Context(), ctx)
+	is.Equal(file.(*budfs.File).Path(), "fuzz")
+	is.Equal(file.(*budfs.File).Target(), "fuzz/../a//b")
+	is.Equal(file.(*budfs.File).Relative(), "../a//b")
+	_, file = geninput.New(bfs, ctx, "fuzz", "fuzz/")
+	is.Equal(file.(*budfs.File).Relative(), "")
+}
+
 // func TestRemoteFS(t *testing.T) {
 // 	is := is.New(t)
 // 	parent := func(t testing.TB, cmd *exec.Cmd) {
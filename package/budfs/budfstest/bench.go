@@ -0,0 +1,46 @@
+package budfstest
+
+import (
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"github.com/livebud/bud/package/budfs"
+)
+
+// BenchmarkGenerator registers gen for numFiles files and generates a fresh
+// batch of those files for each of the b.N iterations.
+func BenchmarkGenerator(b *testing.B, gen budfs.FileGenerator, numFiles int) {
+	b.Helper()
+	fsys := NewMockFS()
+	paths := make([]string, numFiles)
+	for i := 0; i < numFiles; i++ {
+		paths[i] = fmt.Sprintf("bench/%d.txt", i)
+		fsys.FileGenerator(paths[i], gen)
+	}
+	// Warm up once to compute the number of bytes generated per batch
+	size, err := generateAll(fsys, paths)
+	if err != nil {
+		b.Fatalf("budfstest: unable to generate files. %s", err)
+	}
+	b.SetBytes(size)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fsys.Change(paths...)
+		if _, err := generateAll(fsys, paths); err != nil {
+			b.Fatalf("budfstest: unable to generate files. %s", err)
+		}
+	}
+}
+
+func generateAll(fsys fs.FS, paths []string) (size int64, err error) {
+	for _, path := range paths {
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return 0, err
+		}
+		size += int64(len(data))
+	}
+	return size, nil
+}
@@ -0,0 +1,102 @@
+// Package budfstest contains helpers for testing budfs generators.
+package budfstest
+
+import (
+	"io/fs"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/livebud/bud/package/budfs"
+	"github.com/livebud/bud/package/log"
+	"github.com/livebud/bud/package/virtual"
+)
+
+// NewMockFS returns a filesystem with an empty underlying filesystem and a
+// discarded logger. Register generators on it to test them in isolation.
+func NewMockFS() *budfs.FileSystem {
+	return budfs.New(virtual.Map{}, log.Discard)
+}
+
+// FakeTime is the time returned by FakeClock
+var FakeTime = time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// FakeClock returns a clock that always returns FakeTime. Pass it to SetClock
+// so the ModTime of generated files is the same across test runs.
+func FakeClock() func() time.Time {
+	return func() time.Time {
+		return FakeTime
+	}
+}
+
+// Spy wraps a file generator, recording each call
+func Spy(generator budfs.FileGenerator) *GeneratorSpy {
+	return &GeneratorSpy{Generator: generator}
+}
+
+// GeneratorSpy wraps a file generator and records the target paths it was
+// called with.
+type GeneratorSpy struct {
+	Generator budfs.FileGenerator
+	mu        sync.Mutex
+	calls     []string
+}
+
+var _ budfs.FileGenerator = (*GeneratorSpy)(nil)
+
+func (s *GeneratorSpy) GenerateFile(fsys budfs.FS, file *budfs.File) error {
+	s.mu.Lock()
+	s.calls = append(s.calls, file.Target())
+	s.mu.Unlock()
+	return s.Generator.GenerateFile(fsys, file)
+}
+
+// Calls returns the target paths the generator was called with, in order
+func (s *GeneratorSpy) Calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	calls := make([]string, len(s.calls))
+	copy(calls, s.calls)
+	return calls
+}
+
+// Count returns the number of times the generator was called
+func (s *GeneratorSpy) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.calls)
+}
+
+// AssertFile asserts that path exists in fsys and contains want
+func AssertFile(t testing.TB, fsys fs.FS, path, want string) {
+	t.Helper()
+	data, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		t.Fatalf("budfstest: unable to read %q. %s", path, err)
+	}
+	if string(data) != want {
+		t.Fatalf("budfstest: unexpected contents in %q.\nwant: %q\n got: %q", path, want, string(data))
+	}
+}
+
+// AssertDir asserts that the directory at path contains exactly the entry
+// names in want, in order
+func AssertDir(t testing.TB, fsys fs.FS, path string, want []string) {
+	t.Helper()
+	des, err := fs.ReadDir(fsys, path)
+	if err != nil {
+		t.Fatalf("budfstest: unable to read directory %q. %s", path, err)
+	}
+	got := make([]string, len(des))
+	for i, de := range des {
+		got[i] = de.Name()
+	}
+	if len(got) != len(want) {
+		t.Fatalf("budfstest: unexpected entries in %q.\nwant: %q\n got: %q", path, want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("budfstest: unexpected entries in %q.\nwant: %q\n got: %q", path, want, got)
+		}
+	}
+}
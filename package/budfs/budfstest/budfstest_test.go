@@ -0,0 +1,193 @@
+package budfstest_test
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/livebud/bud/internal/is"
+	"github.com/livebud/bud/package/budfs"
+	"github.com/livebud/bud/package/budfs/budfstest"
+	"github.com/livebud/bud/package/log"
+)
+
+func TestMockFS(t *testing.T) {
+	is := is.New(t)
+	fsys := budfstest.NewMockFS()
+	spy := budfstest.Spy(budfs.GenerateFile(func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("a")
+		return nil
+	}))
+	fsys.FileGenerator("bud/a.txt", spy)
+	budfstest.AssertFile(t, fsys, "bud/a.txt", "a")
+	budfstest.AssertDir(t, fsys, "bud", []string{"a.txt"})
+	budfstest.AssertDir(t, fsys, ".", []string{"bud"})
+	is.Equal(spy.Count(), 1)
+	is.Equal(spy.Calls(), []string{"bud/a.txt"})
+	// Cached
+	budfstest.AssertFile(t, fsys, "bud/a.txt", "a")
+	is.Equal(spy.Count(), 1)
+	// Changed
+	fsys.Change("bud/a.txt")
+	budfstest.AssertFile(t, fsys, "bud/a.txt", "a")
+	is.Equal(spy.Count(), 2)
+}
+
+func TestTxtarFS(t *testing.T) {
+	is := is.New(t)
+	fsys, err := budfstest.TxtarFS(`
+-- view/index.svelte --
+<h1>index</h1>
+-- view/about/index.svelte --
+<h1>about</h1>
+`)
+	is.NoErr(err)
+	_, ok := fsys.(fs.ReadDirFS)
+	is.True(ok)
+	_, ok = fsys.(fs.ReadFileFS)
+	is.True(ok)
+	_, ok = fsys.(fs.StatFS)
+	is.True(ok)
+	budfstest.AssertDir(t, fsys, "view", []string{"about", "index.svelte"})
+	budfstest.AssertFile(t, fsys, "view/index.svelte", "<h1>index</h1>\n")
+	budfstest.AssertFile(t, fsys, "view/about/index.svelte", "<h1>about</h1>\n")
+	// Use as the underlying filesystem for generators
+	bfs := budfs.New(fsys, log.Discard)
+	bfs.GenerateFile("bud/view.txt", func(fsys budfs.FS, file *budfs.File) error {
+		code, err := fs.ReadFile(fsys, "view/index.svelte")
+		if err != nil {
+			return err
+		}
+		file.Data = code
+		return nil
+	})
+	budfstest.AssertFile(t, bfs, "bud/view.txt", "<h1>index</h1>\n")
+}
+
+func TestTxtarFSMalformed(t *testing.T) {
+	is := is.New(t)
+	_, err := budfstest.TxtarFS(`
+-- view/index.svelte --
+<h1>index</h1>
+-- view/index.svelte --
+<h1>duplicate</h1>
+`)
+	is.True(err != nil)
+	_, err = budfstest.TxtarFS(`
+-- ../index.svelte --
+<h1>index</h1>
+`)
+	is.True(err != nil)
+}
+
+func TestMustParseFS(t *testing.T) {
+	fsys := budfstest.MustParseFS(t, `
+-- view/index.svelte --
+<h1>index</h1>
+`)
+	budfstest.AssertFile(t, fsys, "view/index.svelte", "<h1>index</h1>\n")
+}
+
+func TestMustParseVirtualFS(t *testing.T) {
+	is := is.New(t)
+	fsys := budfstest.MustParseVirtualFS(t, `
+-- view/index.svelte --
+<h1>index</h1>
+`)
+	budfstest.AssertDir(t, fsys, "view", []string{"index.svelte"})
+	budfstest.AssertFile(t, fsys, "view/index.svelte", "<h1>index</h1>\n")
+	// Writable as a sync target
+	is.NoErr(fsys.WriteFile("view/about.svelte", []byte("<h1>about</h1>"), 0644))
+	budfstest.AssertDir(t, fsys, "view", []string{"about.svelte", "index.svelte"})
+}
+
+func TestSnapshotFS(t *testing.T) {
+	is := is.New(t)
+	fsys := budfstest.NewMockFS()
+	fsys.GenerateDir("bud/view", func(fsys budfs.FS, dir *budfs.Dir) error {
+		dir.GenerateFile("index.svelte", func(fsys budfs.FS, file *budfs.File) error {
+			file.Data = []byte("<h1>index</h1>")
+			return nil
+		})
+		dir.GenerateFile("about/index.svelte", func(fsys budfs.FS, file *budfs.File) error {
+			file.Data = []byte("<h1>about</h1>")
+			return nil
+		})
+		return nil
+	})
+	goldenDir := filepath.Join(t.TempDir(), "testdata")
+	// Unrelated fixtures and stale golden files
+	is.NoErr(os.MkdirAll(filepath.Join(goldenDir, "bud", "view", "old"), 0755))
+	is.NoErr(os.WriteFile(filepath.Join(goldenDir, "bud", "view", "old", "old.svelte"), []byte("old"), 0644))
+	is.NoErr(os.WriteFile(filepath.Join(goldenDir, "fixture.txt"), []byte("fixture"), 0644))
+	t.Setenv("UPDATE_GOLDEN", "1")
+	budfstest.SnapshotFS(t, fsys, "bud", goldenDir)
+	data, err := os.ReadFile(filepath.Join(goldenDir, "bud", "view", "about", "index.svelte"))
+	is.NoErr(err)
+	is.Equal(string(data), "<h1>about</h1>")
+	_, err = os.Stat(filepath.Join(goldenDir, "bud", "view", "old"))
+	is.True(errors.Is(err, fs.ErrNotExist))
+	data, err = os.ReadFile(filepath.Join(goldenDir, "fixture.txt"))
+	is.NoErr(err)
+	is.Equal(string(data), "fixture")
+	t.Setenv("UPDATE_GOLDEN", "")
+	budfstest.SnapshotFS(t, fsys, "bud", goldenDir)
+}
+
+func FuzzFSFuzzer(f *testing.F) {
+	budfstest.FSFuzzer(f, budfs.GenerateFile(func(fsys budfs.FS, file *budfs.File) error {
+		if file.Relative() == "" {
+			return fs.ErrInvalid
+		}
+		// Empty files are allowed
+		if file.Relative() == "empty" {
+			return nil
+		}
+		file.Data = []byte(file.Relative())
+		return nil
+	}))
+}
+
+func BenchmarkGenerator(b *testing.B) {
+	budfstest.BenchmarkGenerator(b, budfs.GenerateFile(func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte(file.Target())
+		return nil
+	}), 100)
+}
+
+func TestRunGeneratorTests(t *testing.T) {
+	budfstest.RunGeneratorTests(t, func(fsys *budfs.FileSystem) {
+		fsys.GenerateFile("bud/view/index.go", func(fsys budfs.FS, file *budfs.File) error {
+			data, err := fsys.Require("view/index.svelte")
+			if err != nil {
+				return err
+			} else if len(bytes.TrimSpace(data)) == 0 {
+				return errors.New("empty svelte file")
+			}
+			file.Data = append([]byte("// "), data...)
+			return nil
+		})
+	}, []budfstest.GeneratorTestCase{
+		{
+			Name: "generated",
+			SourceFS: budfstest.MustParseFS(t, `
+-- view/index.svelte --
+<h1>index</h1>
+`),
+			WantFiles: map[string]string{
+				"bud/view/index.go": "// <h1>index</h1>\n",
+				"view/index.svelte": "<h1>index</h1>\n",
+			},
+		},
+		{
+			Name: "error",
+			SourceFS: budfstest.MustParseFS(t, `
+-- view/index.svelte --
+`),
+			WantError: "empty svelte file",
+		},
+	})
+}
@@ -0,0 +1,53 @@
+package budfstest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/livebud/bud/package/budfs"
+	"github.com/livebud/bud/package/budfs/internal/geninput"
+)
+
+// FSFuzzer registers a fuzz target that calls gen directly with a MockFS and a
+// file whose target is derived from the fuzz corpus. The target paths aren't
+// validated, so gen sees paths that Open would reject. Generators may return
+// errors or leave the file empty, but the target fails when they panic.
+func FSFuzzer(f *testing.F, gen budfs.FileGenerator) {
+	f.Add("index.svelte")
+	f.Add("")
+	f.Add("\x00")
+	f.Add("../index.svelte")
+	f.Add("a//b/./c")
+	f.Add("/index.svelte")
+	f.Add(strings.Repeat("a/", 512) + "a")
+	f.Fuzz(func(t *testing.T, path string) {
+		fsys, file := geninput.New(NewMockFS(), context.Background(), "fuzz", "fuzz/"+path)
+		if err := fuzzGenerate(gen, fsys.(budfs.FS), file.(*budfs.File)); err != nil {
+			var fuzzErr *fuzzError
+			if errors.As(err, &fuzzErr) {
+				t.Fatal(fuzzErr)
+			}
+		}
+	})
+}
+
+// fuzzGenerate calls the generator, turning panics into errors
+func fuzzGenerate(gen budfs.FileGenerator, fsys budfs.FS, file *budfs.File) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &fuzzError{fmt.Sprintf("generator panicked on %q. %v", file.Target(), r)}
+		}
+	}()
+	return gen.GenerateFile(fsys, file)
+}
+
+type fuzzError struct {
+	message string
+}
+
+func (e *fuzzError) Error() string {
+	return "budfstest: " + e.message
+}
@@ -0,0 +1,56 @@
+package budfstest
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/livebud/bud/package/budfs"
+	"github.com/livebud/bud/package/budfs/memfs"
+	"github.com/livebud/bud/package/log"
+)
+
+// GeneratorTestCase is a case for RunGeneratorTests
+type GeneratorTestCase struct {
+	Name      string
+	SourceFS  fs.FS             // Source files, like a TxtarFS. Defaults to empty.
+	WantFiles map[string]string // Expected contents keyed by path
+	WantError string            // Expected error substring or "" for no error
+}
+
+// RunGeneratorTests runs each case as a subtest. Each case gets a new
+// filesystem over its source files with the generators registered by reg.
+// The filesystem is synced to memory, then the files are checked. Sync skips
+// files that don't exist, so generators that fail with fs.ErrNotExist don't
+// cause a WantError.
+func RunGeneratorTests(t *testing.T, reg func(fsys *budfs.FileSystem), cases []GeneratorTestCase) {
+	t.Helper()
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Helper()
+			source := tc.SourceFS
+			if source == nil {
+				source = fstest.MapFS{}
+			}
+			fsys := budfs.New(source, log.Discard)
+			reg(fsys)
+			mem := memfs.New()
+			err := fsys.Sync(mem, ".")
+			if tc.WantError != "" {
+				if err == nil {
+					t.Fatalf("budfstest: expected an error containing %q, but got none", tc.WantError)
+				} else if !strings.Contains(err.Error(), tc.WantError) {
+					t.Fatalf("budfstest: expected an error containing %q, but got %q", tc.WantError, err.Error())
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("budfstest: unable to sync. %s", err)
+			}
+			for path, want := range tc.WantFiles {
+				AssertFile(t, mem, path, want)
+			}
+		})
+	}
+}
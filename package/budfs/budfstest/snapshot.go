@@ -0,0 +1,133 @@
+package budfstest
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// SnapshotFS compares the files in fsys under root with the golden files in
+// goldenDir/root. Run the tests with UPDATE_GOLDEN=1 to write the golden files.
+// Updating only touches files within goldenDir/root, so goldenDir can be shared
+// with other fixtures.
+func SnapshotFS(t *testing.T, fsys fs.FS, root, goldenDir string) {
+	t.Helper()
+	snapshotDir := filepath.Join(goldenDir, filepath.FromSlash(root))
+	files := map[string][]byte{}
+	err := fs.WalkDir(fsys, root, func(fpath string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if de.IsDir() {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, fpath)
+		if err != nil {
+			return err
+		}
+		files[relativePath(root, fpath)] = data
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("budfstest: unable to walk %q. %s", root, err)
+	}
+	if os.Getenv("UPDATE_GOLDEN") == "1" {
+		if err := writeGolden(snapshotDir, files); err != nil {
+			t.Fatalf("budfstest: unable to update golden files in %q. %s", snapshotDir, err)
+		}
+		return
+	}
+	for rel, data := range files {
+		golden, err := os.ReadFile(filepath.Join(snapshotDir, filepath.FromSlash(rel)))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("budfstest: missing golden file for %q. Run with UPDATE_GOLDEN=1 to create it", rel)
+				continue
+			}
+			t.Fatalf("budfstest: unable to read golden file for %q. %s", rel, err)
+		}
+		if string(golden) != string(data) {
+			t.Errorf("budfstest: %q doesn't match its golden file.\nwant: %q\n got: %q", rel, string(golden), string(data))
+		}
+	}
+	// Check for golden files that are no longer generated
+	err = filepath.WalkDir(snapshotDir, func(fpath string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if de.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(snapshotDir, fpath)
+		if err != nil {
+			return err
+		}
+		if _, ok := files[filepath.ToSlash(rel)]; !ok {
+			t.Errorf("budfstest: golden file %q is no longer generated", filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("budfstest: unable to walk golden files in %q. %s", snapshotDir, err)
+	}
+}
+
+// writeGolden writes the files to snapshotDir, removing the golden files that
+// are no longer generated along with any directories they leave empty
+func writeGolden(snapshotDir string, files map[string][]byte) error {
+	for rel, data := range files {
+		fpath := filepath.Join(snapshotDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(fpath, data, 0644); err != nil {
+			return err
+		}
+	}
+	var dirs []string
+	err := filepath.WalkDir(snapshotDir, func(fpath string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if de.IsDir() {
+			dirs = append(dirs, fpath)
+			return nil
+		}
+		rel, err := filepath.Rel(snapshotDir, fpath)
+		if err != nil {
+			return err
+		}
+		if _, ok := files[filepath.ToSlash(rel)]; ok {
+			return nil
+		}
+		return os.Remove(fpath)
+	})
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	// Remove empty directories, deepest first
+	for i := len(dirs) - 1; i > 0; i-- {
+		entries, err := os.ReadDir(dirs[i])
+		if err != nil {
+			return err
+		} else if len(entries) == 0 {
+			if err := os.Remove(dirs[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func relativePath(base, target string) string {
+	if base == "." {
+		return target
+	} else if base == target {
+		return path.Base(target)
+	}
+	return strings.TrimPrefix(target, base+"/")
+}
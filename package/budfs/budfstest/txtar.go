@@ -0,0 +1,68 @@
+package budfstest
+
+import (
+	"fmt"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/livebud/bud/package/virtual"
+	"golang.org/x/tools/txtar"
+)
+
+// TxtarFS parses an inline txtar archive into an in-memory filesystem. The
+// returned filesystem implements fs.ReadDirFS, fs.ReadFileFS and fs.StatFS.
+// Archives with invalid or duplicate file names return an error.
+func TxtarFS(archive string) (fs.FS, error) {
+	files, err := parseTxtar(archive)
+	if err != nil {
+		return nil, err
+	}
+	fsys := fstest.MapFS{}
+	for _, file := range files {
+		fsys[file.Name] = &fstest.MapFile{Data: file.Data}
+	}
+	return fsys, nil
+}
+
+// MustParseFS is like TxtarFS, but fails the test if the archive is malformed
+func MustParseFS(t testing.TB, archive string) fs.FS {
+	t.Helper()
+	fsys, err := TxtarFS(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fsys
+}
+
+// MustParseVirtualFS parses an inline txtar archive into a writable virtual
+// filesystem, failing the test if the archive is malformed. Use this as a
+// sync target.
+func MustParseVirtualFS(t testing.TB, archive string) virtual.FS {
+	t.Helper()
+	files, err := parseTxtar(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := virtual.Tree{}
+	for _, file := range files {
+		if err := fsys.WriteFile(file.Name, file.Data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fsys
+}
+
+func parseTxtar(archive string) ([]txtar.File, error) {
+	ar := txtar.Parse([]byte(archive))
+	seen := map[string]bool{}
+	for _, file := range ar.Files {
+		if !fs.ValidPath(file.Name) || file.Name == "." {
+			return nil, fmt.Errorf("budfstest: invalid file name %q in txtar archive", file.Name)
+		} else if seen[file.Name] {
+			return nil, fmt.Errorf("budfstest: duplicate file name %q in txtar archive", file.Name)
+		}
+		seen[file.Name] = true
+	}
+	return ar.Files, nil
+}
@@ -0,0 +1,116 @@
+// Package fstest contains a conformance test suite for fs.FS implementations.
+package fstest
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+// TestFSConformance runs the standard library's fstest.TestFS along with
+// additional checks that bud relies on. Files are the paths expected to exist
+// within fsys.
+func TestFSConformance(t *testing.T, fsys fs.FS, files ...string) {
+	t.Helper()
+	if err := fstest.TestFS(fsys, files...); err != nil {
+		t.Errorf("fstest: %s", err)
+	}
+	dirs := map[string]bool{".": true}
+	for _, file := range files {
+		for dir := path.Dir(file); !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	for dir := range dirs {
+		testReadDirSorted(t, fsys, dir)
+		testGlobSorted(t, fsys, path.Join(dir, "*"))
+	}
+	for _, file := range files {
+		testStat(t, fsys, file)
+	}
+	testNotExist(t, fsys)
+}
+
+// ReadDir entries are sorted by name
+func testReadDirSorted(t *testing.T, fsys fs.FS, dir string) {
+	t.Helper()
+	des, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		t.Errorf("fstest: unable to read directory %q. %s", dir, err)
+		return
+	}
+	sorted := sort.SliceIsSorted(des, func(i, j int) bool {
+		return des[i].Name() < des[j].Name()
+	})
+	if !sorted {
+		t.Errorf("fstest: entries in %q are not sorted", dir)
+	}
+}
+
+// Glob returns matches in sorted order
+func testGlobSorted(t *testing.T, fsys fs.FS, pattern string) {
+	t.Helper()
+	globber, ok := fsys.(fs.GlobFS)
+	if !ok {
+		return
+	}
+	matches, err := globber.Glob(pattern)
+	if err != nil {
+		t.Errorf("fstest: unable to glob %q. %s", pattern, err)
+		return
+	}
+	if !sort.StringsAreSorted(matches) {
+		t.Errorf("fstest: glob %q returned unsorted matches %v", pattern, matches)
+	}
+}
+
+// Stat info matches the opened file's info
+func testStat(t *testing.T, fsys fs.FS, name string) {
+	t.Helper()
+	stat, err := fs.Stat(fsys, name)
+	if err != nil {
+		t.Errorf("fstest: unable to stat %q. %s", name, err)
+		return
+	}
+	file, err := fsys.Open(name)
+	if err != nil {
+		t.Errorf("fstest: unable to open %q. %s", name, err)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		t.Errorf("fstest: unable to stat opened file %q. %s", name, err)
+		return
+	}
+	if stat.Name() != info.Name() {
+		t.Errorf("fstest: stat name %q does not match opened file name %q", stat.Name(), info.Name())
+	}
+	if stat.Mode() != info.Mode() {
+		t.Errorf("fstest: stat mode %s does not match opened file mode %s for %q", stat.Mode(), info.Mode(), name)
+	}
+	if stat.IsDir() != info.IsDir() {
+		t.Errorf("fstest: stat isdir %t does not match opened file isdir %t for %q", stat.IsDir(), info.IsDir(), name)
+	}
+	if !stat.IsDir() && stat.Size() != info.Size() {
+		t.Errorf("fstest: stat size %d does not match opened file size %d for %q", stat.Size(), info.Size(), name)
+	}
+}
+
+// Opening a path that doesn't exist wraps fs.ErrNotExist
+func testNotExist(t *testing.T, fsys fs.FS) {
+	t.Helper()
+	const name = "fstest-does-not-exist/missing.txt"
+	file, err := fsys.Open(name)
+	if err == nil {
+		file.Close()
+		t.Errorf("fstest: expected opening %q to fail", name)
+		return
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("fstest: expected opening %q to wrap fs.ErrNotExist, got %s", name, err)
+	}
+}
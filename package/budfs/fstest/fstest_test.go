@@ -0,0 +1,30 @@
+package fstest_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/livebud/bud/package/budfs"
+	budfstest "github.com/livebud/bud/package/budfs/fstest"
+	"github.com/livebud/bud/package/log"
+)
+
+func TestMapFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt":             &fstest.MapFile{Data: []byte("a")},
+		"view/index.svelte": &fstest.MapFile{Data: []byte("<h1>index</h1>")},
+	}
+	budfstest.TestFSConformance(t, fsys, "a.txt", "view/index.svelte")
+}
+
+func TestBudFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("a")},
+	}
+	bfs := budfs.New(fsys, log.Discard)
+	bfs.GenerateFile("bud/b.txt", func(fsys budfs.FS, file *budfs.File) error {
+		file.Data = []byte("b")
+		return nil
+	})
+	budfstest.TestFSConformance(t, bfs, "a.txt", "bud/b.txt")
+}
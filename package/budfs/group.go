@@ -0,0 +1,152 @@
+package budfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/livebud/bud/internal/dsync"
+	"github.com/livebud/bud/package/virtual"
+)
+
+type groups struct {
+	mu sync.Mutex
+	m  map[string]*GeneratorGroup
+}
+
+// Group returns the named group of generators, creating it if it doesn't exist
+// yet. Generators registered through the group can be synced and invalidated
+// separately from the rest of the filesystem.
+func (f *FileSystem) Group(name string) *GeneratorGroup {
+	f.groups.mu.Lock()
+	defer f.groups.mu.Unlock()
+	if group, ok := f.groups.m[name]; ok {
+		return group
+	}
+	group := &GeneratorGroup{fsys: f, name: name}
+	f.groups.m[name] = group
+	return group
+}
+
+func (f *FileSystem) group(name string) (*GeneratorGroup, error) {
+	f.groups.mu.Lock()
+	defer f.groups.mu.Unlock()
+	group, ok := f.groups.m[name]
+	if !ok {
+		return nil, fmt.Errorf("budfs: unknown generator group %q", name)
+	}
+	return group, nil
+}
+
+// GeneratorGroup is a named subset of the filesystem's generators
+type GeneratorGroup struct {
+	fsys  *FileSystem
+	name  string
+	mu    sync.Mutex
+	paths []string // Full paths of the registered generators
+}
+
+// Name of the group
+func (g *GeneratorGroup) Name() string {
+	return g.name
+}
+
+func (g *GeneratorGroup) add(path string) {
+	g.mu.Lock()
+	g.paths = append(g.paths, g.fsys.path(path))
+	g.mu.Unlock()
+}
+
+// Paths returns the paths of the generators in the group
+func (g *GeneratorGroup) Paths() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.paths...)
+}
+
+func (g *GeneratorGroup) GenerateFile(path string, fn func(fsys FS, file *File) error) {
+	g.fsys.GenerateFile(path, fn)
+	g.add(path)
+}
+
+func (g *GeneratorGroup) FileGenerator(path string, generator FileGenerator) {
+	g.GenerateFile(path, generator.GenerateFile)
+}
+
+func (g *GeneratorGroup) GenerateDir(path string, fn func(fsys FS, dir *Dir) error) {
+	g.fsys.GenerateDir(path, fn)
+	g.add(path)
+}
+
+func (g *GeneratorGroup) DirGenerator(path string, generator DirGenerator) {
+	g.GenerateDir(path, generator.GenerateDir)
+}
+
+func (g *GeneratorGroup) ServeFile(dir string, fn func(fsys FS, file *File) error) {
+	g.fsys.ServeFile(dir, fn)
+	g.add(dir)
+}
+
+// InvalidateGroup evicts the cached files of the named group's generators,
+// including the files generated within the group's directories
+func (f *FileSystem) InvalidateGroup(name string) {
+	group, err := f.group(name)
+	if err != nil {
+		return
+	}
+	paths := group.Paths()
+	f.cache.Range(func(key string, _ virtual.Entry) bool {
+		for _, path := range paths {
+			if key == path || strings.HasPrefix(key, path+"/") {
+				f.log.Debug("budfs: invalidate group", "group", name, "path", key)
+				f.cache.Delete(key)
+				break
+			}
+		}
+		return true
+	})
+}
+
+// SyncGroup syncs the named group's generated files within the "to" directory
+// to the writable filesystem. Files generated outside of the group are left
+// as-is.
+func (f *FileSystem) SyncGroup(name string, writable virtual.FS, to string) error {
+	group, err := f.group(name)
+	if err != nil {
+		return err
+	}
+	for _, full := range group.Paths() {
+		rel, ok := f.relative(full)
+		if !ok || !(to == "." || rel == to || strings.HasPrefix(rel, to+"/")) {
+			continue
+		}
+		if err := f.syncPath(writable, rel); err != nil {
+			return fmt.Errorf("budfs: unable to sync %q in group %q. %w", rel, name, err)
+		}
+	}
+	return nil
+}
+
+func (f *FileSystem) syncPath(writable virtual.FS, rel string) error {
+	stat, err := fs.Stat(f, rel)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return writable.RemoveAll(rel)
+		}
+		return err
+	}
+	if stat.IsDir() {
+		return dsync.Dir(f, rel, writable, rel)
+	}
+	data, err := fs.ReadFile(f, rel)
+	if err != nil {
+		return err
+	}
+	if err := writable.MkdirAll(path.Dir(rel), 0755); err != nil {
+		return err
+	}
+	return writable.WriteFile(rel, data, 0644)
+}
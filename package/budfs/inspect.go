@@ -0,0 +1,71 @@
+package budfs
+
+import (
+	"sort"
+	"time"
+
+	"github.com/livebud/bud/package/virtual"
+	"github.com/livebud/bud/package/virtual/vcache"
+)
+
+// CacheEntry is the usage of a cached path
+type CacheEntry struct {
+	Path       string
+	Size       int64     // Size of the file data in bytes
+	Hits       int64     // Number of times the entry was read from the cache
+	LastAccess time.Time // Last time the entry was set or read
+}
+
+// CacheInspector reports the cache usage for monitoring. Inspectors are safe
+// to use from other goroutines while generators are running.
+type CacheInspector interface {
+	Entries() []CacheEntry
+	HitRate() float64
+	TotalBytes() int64
+}
+
+// CacheInspector returns an inspector for the filesystem's cache. Hits and
+// access times are only tracked by the default LRU cache, so they're zero
+// for other shared caches.
+func (f *FileSystem) CacheInspector() CacheInspector {
+	return &cacheInspector{f.root}
+}
+
+type cacheInspector struct {
+	fsys *FileSystem
+}
+
+// Entries returns the cached entries, sorted by path
+func (c *cacheInspector) Entries() (entries []CacheEntry) {
+	if lru, ok := c.fsys.cache.(*vcache.LRUCache); ok {
+		for _, stat := range lru.Stats() {
+			entries = append(entries, CacheEntry(stat))
+		}
+		return entries
+	}
+	c.fsys.cache.Range(func(path string, entry virtual.Entry) bool {
+		size := int64(0)
+		if file, ok := entry.(*virtual.File); ok {
+			size = int64(len(file.Data))
+		}
+		entries = append(entries, CacheEntry{Path: path, Size: size})
+		return true
+	})
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries
+}
+
+// HitRate returns the fraction of cache reads that were hits
+func (c *cacheInspector) HitRate() float64 {
+	if lru, ok := c.fsys.cache.(*vcache.LRUCache); ok {
+		return lru.HitRate()
+	}
+	return 0
+}
+
+// TotalBytes returns the total size of the cached file data
+func (c *cacheInspector) TotalBytes() int64 {
+	return c.fsys.CacheBytes()
+}
@@ -0,0 +1,11 @@
+// Package geninput lets budfstest call generators directly without widening
+// the budfs API.
+package geninput
+
+import "context"
+
+// New is set by budfs. It returns the budfs.FS and *budfs.File that a file
+// server registered at dir within fsys, a *budfs.FileSystem, is called with
+// when target is opened. Unlike Open, target isn't validated. The values are
+// untyped because this package can't import budfs.
+var New func(fsys interface{}, ctx context.Context, dir, target string) (interface{}, interface{})
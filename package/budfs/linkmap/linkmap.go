@@ -1,6 +1,8 @@
 package linkmap
 
 import (
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/livebud/bud/package/log"
@@ -30,6 +32,53 @@ func (m *Map) Scope(path string) *List {
 	return list
 }
 
+// Delete the links from path
+func (m *Map) Delete(path string) {
+	m.sm.Delete(path)
+}
+
+// Move rewrites the links within from to be within to. This includes the
+// links from paths within from and the links pointing to paths within from.
+// Select functions can't be rewritten, so they're kept as-is.
+func (m *Map) Move(from, to string) {
+	rebase := func(path string) (string, bool) {
+		if path == from {
+			return to, true
+		} else if strings.HasPrefix(path, from+"/") {
+			return to + strings.TrimPrefix(path, from), true
+		}
+		return path, false
+	}
+	var moved []string
+	m.sm.Range(func(key, value interface{}) bool {
+		list := value.(*List)
+		list.mu.Lock()
+		for path := range list.tos {
+			if rebased, ok := rebase(path); ok {
+				delete(list.tos, path)
+				list.tos[rebased] = struct{}{}
+			}
+		}
+		list.mu.Unlock()
+		if _, ok := rebase(key.(string)); ok {
+			moved = append(moved, key.(string))
+		}
+		return true
+	})
+	for _, path := range moved {
+		value, ok := m.sm.LoadAndDelete(path)
+		if !ok {
+			continue
+		}
+		list := value.(*List)
+		rebased, _ := rebase(path)
+		list.mu.Lock()
+		list.from = rebased
+		list.mu.Unlock()
+		m.sm.Store(rebased, list)
+	}
+}
+
 func (m *Map) Range(fn func(path string, list *List) bool) {
 	m.sm.Range(func(key, value interface{}) bool {
 		return fn(key.(string), value.(*List))
@@ -75,3 +124,21 @@ func (l *List) Check(path string) bool {
 	}
 	return false
 }
+
+// Paths returns the linked paths, sorted alphabetically
+func (l *List) Paths() (paths []string) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for to := range l.tos {
+		paths = append(paths, to)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Selectors returns the number of select functions
+func (l *List) Selectors() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.fns)
+}
@@ -91,3 +91,36 @@ func TestLinkRange(t *testing.T) {
 	is.True(expect["bud/view.go"])
 	is.True(expect["bud/controller.go"])
 }
+
+func TestPaths(t *testing.T) {
+	is := is.New(t)
+	log := testlog.New()
+	linkMap := linkmap.New(log)
+	list := linkMap.Scope("bud/view.go")
+	list.Link("test", "view/index.svelte", "controller/controller.go")
+	list.Link("test", "view/index.svelte")
+	list.Select("test", func(path string) bool {
+		return path == "view/about/index.svelte"
+	})
+	is.Equal(list.Paths(), []string{"controller/controller.go", "view/index.svelte"})
+	is.Equal(list.Selectors(), 1)
+}
+
+func TestMove(t *testing.T) {
+	is := is.New(t)
+	log := testlog.New()
+	linkMap := linkmap.New(log)
+	list := linkMap.Scope("bud/view/index.go")
+	list.Link("test", "view/index.svelte")
+	list = linkMap.Scope("bud/app.go")
+	list.Link("test", "bud/view/index.go", "bud/viewer.go")
+	linkMap.Move("bud/view", "bud/page")
+	_, ok := linkMap.Get("bud/view/index.go")
+	is.True(!ok)
+	list, ok = linkMap.Get("bud/page/index.go")
+	is.True(ok)
+	is.Equal(list.Paths(), []string{"view/index.svelte"})
+	list, ok = linkMap.Get("bud/app.go")
+	is.True(ok)
+	is.Equal(list.Paths(), []string{"bud/page/index.go", "bud/viewer.go"})
+}
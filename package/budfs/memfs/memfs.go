@@ -0,0 +1,264 @@
+// Package memfs is an in-memory filesystem that's safe for concurrent reads
+// and writes.
+package memfs
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livebud/bud/package/virtual"
+)
+
+// New creates an empty in-memory filesystem
+func New() *FS {
+	return &FS{}
+}
+
+// FS is an in-memory filesystem backed by a sync.Map. FS is safe for
+// concurrent use. Stored files are never modified in place, so open files
+// aren't affected by later writes.
+type FS struct {
+	sm sync.Map // path -> *virtual.File, directories have fs.ModeDir set
+}
+
+var _ virtual.FS = (*FS)(nil)
+var _ fs.ReadDirFS = (*FS)(nil)
+var _ fs.StatFS = (*FS)(nil)
+
+func (f *FS) load(name string) (*virtual.File, bool) {
+	value, ok := f.sm.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return value.(*virtual.File), true
+}
+
+// Open a file or directory. Listing a directory scans every stored path.
+func (f *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return virtual.New(&virtual.Dir{
+			Path:    ".",
+			Mode:    fs.ModeDir | 0755,
+			Entries: f.entries(name),
+		}), nil
+	}
+	file, ok := f.load(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if file.IsDir() {
+		return virtual.New(&virtual.Dir{
+			Path:    name,
+			Mode:    file.Mode,
+			ModTime: file.ModTime,
+			Entries: f.entries(name),
+		}), nil
+	}
+	// Copy the file since virtual files track their path
+	return virtual.New(&virtual.File{
+		Path:    name,
+		Data:    file.Data,
+		Mode:    file.Mode,
+		ModTime: file.ModTime,
+	}), nil
+}
+
+// entries returns the sorted children of dir
+func (f *FS) entries(dir string) (entries []fs.DirEntry) {
+	prefix := dir + "/"
+	if dir == "." {
+		prefix = ""
+	}
+	f.sm.Range(func(key, value interface{}) bool {
+		name := key.(string)
+		if !strings.HasPrefix(name, prefix) || strings.Contains(name[len(prefix):], "/") {
+			return true
+		}
+		file := value.(*virtual.File)
+		entries = append(entries, &virtual.DirEntry{
+			Path:    name,
+			Size:    int64(len(file.Data)),
+			Mode:    file.Mode,
+			ModTime: file.ModTime,
+		})
+		return true
+	})
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries
+}
+
+// ReadDir reads the named directory
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if name != "." {
+		file, ok := f.load(name)
+		if !ok {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+		} else if !file.IsDir() {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+		}
+	}
+	return f.entries(name), nil
+}
+
+// Stat returns the file info of the named file or directory
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return (&virtual.Dir{Path: ".", Mode: fs.ModeDir | 0755}).Info()
+	}
+	file, ok := f.load(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	} else if file.IsDir() {
+		return (&virtual.Dir{Path: name, Mode: file.Mode, ModTime: file.ModTime}).Info()
+	}
+	return file.Info()
+}
+
+// MkdirAll creates the directory along with any missing parents
+func (f *FS) MkdirAll(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return nil
+	}
+	if err := f.MkdirAll(path.Dir(name), perm); err != nil {
+		return err
+	}
+	dir := &virtual.File{Path: name, Mode: perm | fs.ModeDir, ModTime: time.Now()}
+	value, _ := f.sm.LoadOrStore(name, dir)
+	if !value.(*virtual.File).IsDir() {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	return nil
+}
+
+// WriteFile writes the file, creating any missing parent directories
+func (f *FS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := f.MkdirAll(path.Dir(name), 0755); err != nil {
+		return err
+	}
+	if file, ok := f.load(name); ok && file.IsDir() {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrExist}
+	}
+	// Copy the data so the caller can reuse the slice
+	f.sm.Store(name, &virtual.File{
+		Path:    name,
+		Data:    append([]byte(nil), data...),
+		Mode:    perm &^ fs.ModeDir,
+		ModTime: time.Now(),
+	})
+	return nil
+}
+
+// Remove removes a file or an empty directory
+func (f *FS) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	file, ok := f.load(name)
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	} else if file.IsDir() && len(f.entries(name)) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrExist}
+	}
+	f.sm.Delete(name)
+	return nil
+}
+
+// RemoveAll removes the path and any children it contains. Removing a path
+// that doesn't exist isn't an error.
+func (f *FS) RemoveAll(name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	f.sm.Range(func(key, _ interface{}) bool {
+		p := key.(string)
+		if name == "." || p == name || strings.HasPrefix(p, name+"/") {
+			f.sm.Delete(p)
+		}
+		return true
+	})
+	return nil
+}
+
+// Sub returns a filesystem rooted at dir
+func (f *FS) Sub(dir string) (virtual.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	return &subFS{dir, f}, nil
+}
+
+type subFS struct {
+	dir  string
+	fsys *FS
+}
+
+var _ virtual.FS = (*subFS)(nil)
+
+// join the name with the sub directory, ensuring the name can't escape it
+func (s *subFS) join(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(s.dir, name), nil
+}
+
+func (s *subFS) Open(name string) (fs.File, error) {
+	full, err := s.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return s.fsys.Open(full)
+}
+
+func (s *subFS) MkdirAll(name string, perm fs.FileMode) error {
+	full, err := s.join("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return s.fsys.MkdirAll(full, perm)
+}
+
+func (s *subFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	full, err := s.join("write", name)
+	if err != nil {
+		return err
+	}
+	return s.fsys.WriteFile(full, data, perm)
+}
+
+func (s *subFS) RemoveAll(name string) error {
+	full, err := s.join("remove", name)
+	if err != nil {
+		return err
+	}
+	return s.fsys.RemoveAll(full)
+}
+
+func (s *subFS) Sub(dir string) (virtual.FS, error) {
+	full, err := s.join("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	return s.fsys.Sub(full)
+}
@@ -0,0 +1,86 @@
+package memfs_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/livebud/bud/internal/is"
+	"github.com/livebud/bud/package/budfs/memfs"
+)
+
+func TestFS(t *testing.T) {
+	is := is.New(t)
+	fsys := memfs.New()
+	is.NoErr(fsys.WriteFile("go.mod", []byte("module app.com"), 0644))
+	is.NoErr(fsys.WriteFile("view/index.svelte", []byte("<h1>index</h1>"), 0644))
+	is.NoErr(fsys.WriteFile("view/about/index.svelte", []byte("<h1>about</h1>"), 0644))
+	is.NoErr(fsys.MkdirAll("public/empty", 0755))
+	is.NoErr(fstest.TestFS(fsys, "go.mod", "view/index.svelte", "view/about/index.svelte", "public/empty"))
+	data, err := fs.ReadFile(fsys, "view/index.svelte")
+	is.NoErr(err)
+	is.Equal(string(data), "<h1>index</h1>")
+	des, err := fsys.ReadDir("view")
+	is.NoErr(err)
+	is.Equal(len(des), 2)
+	is.Equal(des[0].Name(), "about")
+	is.True(des[0].IsDir())
+	is.Equal(des[1].Name(), "index.svelte")
+	stat, err := fsys.Stat("view/about")
+	is.NoErr(err)
+	is.True(stat.IsDir())
+	// Writes can't replace directories
+	err = fsys.WriteFile("view", nil, 0644)
+	is.True(errors.Is(err, fs.ErrExist))
+	// Remove only removes empty directories
+	err = fsys.Remove("view")
+	is.True(errors.Is(err, fs.ErrExist))
+	is.NoErr(fsys.Remove("public/empty"))
+	_, err = fsys.Stat("public/empty")
+	is.True(errors.Is(err, fs.ErrNotExist))
+	is.NoErr(fsys.RemoveAll("view"))
+	_, err = fsys.Stat("view/about/index.svelte")
+	is.True(errors.Is(err, fs.ErrNotExist))
+	_, err = fs.ReadFile(fsys, "go.mod")
+	is.NoErr(err)
+}
+
+func TestSub(t *testing.T) {
+	is := is.New(t)
+	fsys := memfs.New()
+	sub, err := fsys.Sub("bud")
+	is.NoErr(err)
+	is.NoErr(sub.WriteFile("view/index.go", []byte("package view"), 0644))
+	data, err := fs.ReadFile(fsys, "bud/view/index.go")
+	is.NoErr(err)
+	is.Equal(string(data), "package view")
+	// Paths can't escape the sub directory
+	err = sub.WriteFile("../go.mod", nil, 0644)
+	is.True(errors.Is(err, fs.ErrInvalid))
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	is := is.New(t)
+	fsys := memfs.New()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			path := fmt.Sprintf("view/%d/index.svelte", i%5)
+			if err := fsys.WriteFile(path, []byte(fmt.Sprint(i)), 0644); err != nil {
+				t.Error(err)
+			}
+			if _, err := fs.ReadDir(fsys, "view"); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	des, err := fsys.ReadDir("view")
+	is.NoErr(err)
+	is.Equal(len(des), 5)
+}
@@ -1,6 +1,7 @@
 package mergefs
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -13,17 +14,59 @@ import (
 )
 
 // Merge the filesystems together. When there are conflicts, the earlier
-// filesystem has priority.
+// filesystem has priority. Directories that exist in multiple filesystems have
+// their entries merged.
 func Merge(fileSystems ...fs.FS) *FS {
-	return &FS{fileSystems}
+	return MergeWith(MergeDir, fileSystems...)
 }
 
+// MergeWith merges the filesystems together, resolving directories that exist
+// in multiple filesystems with the policy
+func MergeWith(policy DirPolicy, fileSystems ...fs.FS) *FS {
+	return &FS{policy, fileSystems}
+}
+
+// DirPolicy decides what happens when a directory exists in more than one
+// filesystem
+type DirPolicy uint8
+
+const (
+	// MergeDir combines the entries of every filesystem, deduplicating by name.
+	// Entries from earlier filesystems have priority.
+	MergeDir DirPolicy = iota
+	// OverlayDir uses the directory from the earliest filesystem, hiding the
+	// directories in later filesystems entirely.
+	OverlayDir
+)
+
 type FS struct {
+	policy      DirPolicy
 	fileSystems []fs.FS
 }
 
+// ContextFS is an optional interface for filesystems that accept a context
+// when opening files
+type ContextFS interface {
+	fs.FS
+	OpenContext(ctx context.Context, path string) (fs.File, error)
+}
+
+// openContext opens the path, passing the context through if fsys supports it
+func openContext(ctx context.Context, fsys fs.FS, path string) (fs.File, error) {
+	if cfs, ok := fsys.(ContextFS); ok {
+		return cfs.OpenContext(ctx, path)
+	}
+	return fsys.Open(path)
+}
+
 // Open finds the first path in fileSystems
 func (f *FS) Open(path string) (fs.File, error) {
+	return f.OpenContext(context.Background(), path)
+}
+
+// OpenContext finds the first path in fileSystems, passing the context through
+// to filesystems that implement ContextFS
+func (f *FS) OpenContext(ctx context.Context, path string) (fs.File, error) {
 	if !fs.ValidPath(path) {
 		return nil, &fs.PathError{
 			Op:   "open",
@@ -34,7 +77,7 @@ func (f *FS) Open(path string) (fs.File, error) {
 	var dirs []dir
 	notExists := &notExists{path: path}
 	for _, fsys := range f.fileSystems {
-		file, err := fsys.Open(path)
+		file, err := openContext(ctx, fsys, path)
 		if err != nil {
 			if errors.Is(err, fs.ErrNotExist) {
 				notExists.errors = append(notExists.errors, err)
@@ -47,6 +90,10 @@ func (f *FS) Open(path string) (fs.File, error) {
 		if err != nil {
 			return nil, err
 		}
+		// The earliest filesystem wins entirely when overlaying
+		if f.policy == OverlayDir {
+			return file, nil
+		}
 		// Files always have priority. If it's a file, return right away.
 		if !stat.IsDir() {
 			return file, nil
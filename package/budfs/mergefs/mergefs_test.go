@@ -145,3 +145,46 @@ func TestErrorPropagation(t *testing.T) {
 	}, ". ")
 	is.Equal(err.Error(), expect)
 }
+
+func TestOverlayDir(t *testing.T) {
+	is := is.New(t)
+	a := fstest.MapFS{
+		"view/index.svelte": &fstest.MapFile{Data: []byte("a index")},
+	}
+	b := fstest.MapFS{
+		"view/index.svelte": &fstest.MapFile{Data: []byte("b index")},
+		"view/about.svelte": &fstest.MapFile{Data: []byte("b about")},
+		"main.go":           &fstest.MapFile{Data: []byte("package main")},
+	}
+	// Entries are merged by default
+	merged := mergefs.Merge(a, b)
+	des, err := fs.ReadDir(merged, "view")
+	is.NoErr(err)
+	is.Equal(len(des), 2)
+	is.Equal(des[0].Name(), "about.svelte")
+	is.Equal(des[1].Name(), "index.svelte")
+	data, err := fs.ReadFile(merged, "view/index.svelte")
+	is.NoErr(err)
+	is.Equal(string(data), "a index")
+	// The first directory wins when overlaying
+	overlay := mergefs.MergeWith(mergefs.OverlayDir, a, b)
+	des, err = fs.ReadDir(overlay, "view")
+	is.NoErr(err)
+	is.Equal(len(des), 1)
+	is.Equal(des[0].Name(), "index.svelte")
+	// Missing paths still fall through
+	data, err = fs.ReadFile(overlay, "main.go")
+	is.NoErr(err)
+	is.Equal(string(data), "package main")
+	// Walking enumerates every merged entry
+	var paths []string
+	err = fs.WalkDir(merged, ".", func(path string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	is.NoErr(err)
+	is.Equal(paths, []string{".", "main.go", "view", "view/about.svelte", "view/index.svelte"})
+}
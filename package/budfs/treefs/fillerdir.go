@@ -19,7 +19,9 @@ func (f *fillerDir) Generate(target string) (fs.File, error) {
 	if target != path {
 		return nil, fmt.Errorf("treefs: path doesn't match target in filler directory %s != %s", path, target)
 	}
-	children := f.node.Children()
+	f.node.mu.RLock()
+	children := f.node.visibleChildren()
+	f.node.mu.RUnlock()
 	var entries []fs.DirEntry
 	// TODO: run in parallel
 	for _, child := range children {
@@ -47,23 +49,30 @@ type dirEntry struct {
 var _ fs.DirEntry = (*dirEntry)(nil)
 
 func (e *dirEntry) Name() string {
+	e.node.mu.RLock()
+	defer e.node.mu.RUnlock()
 	return e.node.name
 }
 
 func (e *dirEntry) IsDir() bool {
-	return e.node.mode.IsDir()
+	return e.Type().IsDir()
 }
 
 func (e *dirEntry) Type() fs.FileMode {
+	e.node.mu.RLock()
+	defer e.node.mu.RUnlock()
 	return e.node.mode
 }
 
 func (e *dirEntry) Info() (fs.FileInfo, error) {
+	e.node.mu.RLock()
 	value := e.node.generator
+	path := e.node.path
+	e.node.mu.RUnlock()
 	if value == nil {
 		value = &fillerDir{e.node}
 	}
-	file, err := value.Generate(e.node.Path())
+	file, err := value.Generate(path)
 	if err != nil {
 		return nil, err
 	}
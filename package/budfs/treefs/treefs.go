@@ -1,10 +1,12 @@
 package treefs
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/livebud/bud/package/virtual"
 	"github.com/xlab/treeprint"
@@ -17,6 +19,7 @@ func New(name string) *Node {
 		kind:      kindFiller,
 		childMap:  map[string]*Node{},
 		generator: nil,
+		mu:        new(sync.RWMutex),
 	}
 	root.path = computePath(root)
 	root.generator = &fillerDir{root}
@@ -33,6 +36,25 @@ type Generator interface {
 	Generate(target string) (fs.File, error)
 }
 
+// ContextGenerator is an optional interface for generators that accept the
+// context passed to OpenContext
+type ContextGenerator interface {
+	Generator
+	GenerateContext(ctx context.Context, target string) (fs.File, error)
+}
+
+func generate(ctx context.Context, generator Generator, target string) (fs.File, error) {
+	if cg, ok := generator.(ContextGenerator); ok {
+		return cg.GenerateContext(ctx, target)
+	}
+	return generator.Generate(target)
+}
+
+// InternalDir is the name of directories that hold generators internal to bud.
+// Nodes with this name are hidden from directory listings when they're
+// registered.
+const InternalDir = "__bud_internal"
+
 type nodeKind uint8
 
 const (
@@ -48,6 +70,11 @@ type Node struct {
 	parent    *Node
 	childMap  map[string]*Node
 	generator Generator
+	hidden    bool
+
+	// mu is shared by every node in the tree. It guards the tree's structure
+	// and node attributes, but it's never held while generators run.
+	mu *sync.RWMutex
 }
 
 func computePath(n *Node) (path string) {
@@ -73,15 +100,68 @@ func computePath(n *Node) (path string) {
 }
 
 func (n *Node) Path() string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
 	return n.path
 }
 
 func (n *Node) Mode() fs.FileMode {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
 	return n.mode
 }
 
-func (n *Node) Entries() (entries []fs.DirEntry) {
+// Generator returns the node's generator. Returns false for filler
+// directories.
+func (n *Node) Generator() (Generator, bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.kind != kindGenerator {
+		return nil, false
+	}
+	return n.generator, true
+}
+
+// Walk the tree depth-first in alphanumeric order, starting with the node
+// itself. Returning false from fn skips the node's children. The tree isn't
+// locked while fn runs, so fn may modify the tree.
+func (n *Node) Walk(fn func(node *Node) bool) {
+	if !fn(n) {
+		return
+	}
 	for _, child := range n.Children() {
+		child.Walk(fn)
+	}
+}
+
+// walk is like Walk, but for callers that already hold the lock
+func (n *Node) walk(fn func(node *Node)) {
+	fn(n)
+	for _, child := range n.children() {
+		child.walk(fn)
+	}
+}
+
+// SetVisible shows or hides the node from directory listings. Hidden nodes can
+// still be opened directly.
+func (n *Node) SetVisible(visible bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.hidden = !visible
+}
+
+// Visible returns true if the node is shown in directory listings
+func (n *Node) Visible() bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return !n.hidden
+}
+
+// Entries returns the visible children as directory entries
+func (n *Node) Entries() (entries []fs.DirEntry) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	for _, child := range n.visibleChildren() {
 		entries = append(entries, child.dirEntry())
 	}
 	return entries
@@ -99,6 +179,12 @@ func (n *Node) child(name string) (*Node, bool) {
 
 // Children returns a list of children, ordered alphanumerically.
 func (n *Node) Children() (children []*Node) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.children()
+}
+
+func (n *Node) children() (children []*Node) {
 	children = make([]*Node, len(n.childMap))
 	i := 0
 	for _, child := range n.childMap {
@@ -111,11 +197,26 @@ func (n *Node) Children() (children []*Node) {
 	return children
 }
 
+// visibleChildren returns the children that aren't hidden
+func (n *Node) visibleChildren() (children []*Node) {
+	for _, child := range n.children() {
+		if child.hidden {
+			continue
+		}
+		children = append(children, child)
+	}
+	return children
+}
+
 func (n *Node) DirGenerator(path string, generator Generator) *Node {
+	n.mu.Lock()
+	defer n.mu.Unlock()
 	return n.insert(path, fs.ModeDir, generator)
 }
 
 func (n *Node) FileGenerator(path string, generator Generator) *Node {
+	n.mu.Lock()
+	defer n.mu.Unlock()
 	return n.insert(path, fs.FileMode(0), generator)
 }
 
@@ -130,6 +231,8 @@ func (n *Node) insert(path string, mode fs.FileMode, generator Generator) *Node
 			name:     segments[last],
 			parent:   parent,
 			childMap: map[string]*Node{},
+			hidden:   segments[last] == InternalDir,
+			mu:       n.mu,
 		}
 		child.path = computePath(child)
 		parent.childMap[segments[last]] = child
@@ -141,6 +244,17 @@ func (n *Node) insert(path string, mode fs.FileMode, generator Generator) *Node
 	return child
 }
 
+// MkdirAll creates the filler directories along path that don't exist yet and
+// returns the node at path
+func (n *Node) MkdirAll(path string) *Node {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if path == "." {
+		return n
+	}
+	return n.mkdirAll(strings.Split(path, "/"))
+}
+
 func (n *Node) mkdirAll(segments []string) *Node {
 	parent := n
 	// Create the branches in the directory tree, if they don't exist already.
@@ -154,6 +268,8 @@ func (n *Node) mkdirAll(segments []string) *Node {
 				parent:    parent,
 				childMap:  map[string]*Node{},
 				generator: nil,
+				hidden:    segment == InternalDir,
+				mu:        n.mu,
 			}
 			child.path = computePath(child)
 			child.generator = &fillerDir{child}
@@ -173,19 +289,27 @@ func formatNode(node *Node) string {
 
 // Print the nodes in the tree.
 func (n *Node) Print() string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
 	tp := treeprint.NewWithRoot(formatNode(n))
 	n.print(tp)
 	return tp.String()
 }
 
 func (n *Node) print(tp treeprint.Tree) {
-	for _, child := range n.Children() {
+	for _, child := range n.children() {
 		cp := tp.AddBranch(formatNode(child))
 		child.print(cp)
 	}
 }
 
 func (n *Node) Find(path string) (node *Node, found bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.find(path)
+}
+
+func (n *Node) find(path string) (node *Node, found bool) {
 	// Special case to find the root node
 	if path == "." {
 		return n, true
@@ -203,6 +327,12 @@ func (n *Node) Find(path string) (node *Node, found bool) {
 }
 
 func (n *Node) FindByPrefix(path string) (node *Node, prefix string, found bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.findByPrefix(path)
+}
+
+func (n *Node) findByPrefix(path string) (node *Node, prefix string, found bool) {
 	// Special case to find the root node
 	if path == "." {
 		return n, path, true
@@ -253,7 +383,73 @@ func (n *Node) findAncestor(child *Node, match func(n *Node) bool) (*Node, int,
 	return nil, nth, false
 }
 
+// Replace the generator at path, keeping the node's mode and children. Returns
+// an error if there's no generator at path. Replace doesn't evict anything the
+// previous generator cached, so budfs callers should use
+// FileSystem.ReplaceFile instead.
+func (n *Node) Replace(path string, generator Generator) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	node, found := n.find(path)
+	if !found || node.kind != kindGenerator {
+		return formatError(fs.ErrNotExist, "unable to replace %q because there's no generator", path)
+	}
+	node.generator = generator
+	return nil
+}
+
+// Move the node at from to to, along with its children. Missing parent
+// directories of to are created. Returns an error if from doesn't exist or to
+// already exists.
+func (n *Node) Move(from, to string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	node, found := n.find(from)
+	if !found || node == n {
+		return formatError(fs.ErrNotExist, "unable to move %q because it doesn't exist", from)
+	}
+	if to == "." || strings.HasPrefix(to, from+"/") {
+		return formatError(fs.ErrInvalid, "unable to move %q into %q", from, to)
+	}
+	if _, found := n.find(to); found {
+		return formatError(fs.ErrExist, "unable to move %q because %q already exists", from, to)
+	}
+	segments := strings.Split(to, "/")
+	last := len(segments) - 1
+	parent := n.mkdirAll(segments[:last])
+	delete(node.parent.childMap, node.name)
+	node.name = segments[last]
+	node.parent = parent
+	parent.childMap[node.name] = node
+	// Recompute the paths of the moved subtree
+	node.walk(func(child *Node) {
+		child.path = computePath(child)
+	})
+	return nil
+}
+
+// Prune removes the filler directories that don't contain any generators and
+// returns the number of removed nodes
+func (n *Node) Prune() (pruned int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.prune()
+}
+
+func (n *Node) prune() (pruned int) {
+	for _, child := range n.children() {
+		pruned += child.prune()
+		if child.kind == kindFiller && len(child.childMap) == 0 {
+			delete(n.childMap, child.name)
+			pruned++
+		}
+	}
+	return pruned
+}
+
 func (n *Node) Delete(path ...string) (node *Node, found bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
 	var parent *Node
 	node = n
 	// Traverse the children keyed by segments
@@ -281,26 +477,35 @@ func (n *Node) Delete(path ...string) (node *Node, found bool) {
 }
 
 func (n *Node) Open(target string) (fs.File, error) {
+	return n.OpenContext(context.Background(), target)
+}
+
+// OpenContext opens the target, passing the context through to generators that
+// implement ContextGenerator
+func (n *Node) OpenContext(ctx context.Context, target string) (fs.File, error) {
 	if !fs.ValidPath(target) {
 		return nil, formatError(fs.ErrInvalid, "invalid target path %q", target)
 	}
-	return n.open(target)
+	return n.open(ctx, target)
 }
 
-func (n *Node) open(target string) (fs.File, error) {
+func (n *Node) open(ctx context.Context, target string) (fs.File, error) {
 	// When targeting directories directly, they are simply a virtual dirs
 	rel := relativePath(n.Path(), target)
 	if rel == "." {
-		children := n.Children()
+		n.mu.RLock()
+		children := n.visibleChildren()
 		entries := make([]fs.DirEntry, len(children))
 		for i, child := range children {
 			entries[i] = child.dirEntry()
 		}
-		return virtual.New(&virtual.Dir{
-			Path:    n.Path(),
-			Mode:    n.Mode(),
+		dir := &virtual.Dir{
+			Path:    n.path,
+			Mode:    n.mode,
 			Entries: entries,
-		}), nil
+		}
+		n.mu.RUnlock()
+		return virtual.New(dir), nil
 	}
 	// Find the closest match in the tree
 	node, _, ok := n.FindByPrefix(rel)
@@ -311,8 +516,12 @@ func (n *Node) open(target string) (fs.File, error) {
 	if node.Path() != target && node.Mode().IsRegular() {
 		return nil, formatError(fs.ErrNotExist, "%q file generator doesn't match %q target", n.Path(), target)
 	}
-	// Run the generators
-	return node.generator.Generate(target)
+	// Run the generators without holding the lock, since generators may
+	// register more generators
+	n.mu.RLock()
+	generator := node.generator
+	n.mu.RUnlock()
+	return generate(ctx, generator, target)
 }
 
 func relativePath(base, target string) string {
@@ -1,14 +1,19 @@
 package treefs_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"sync/atomic"
 	"testing"
 	"testing/fstest"
 
 	"github.com/livebud/bud/internal/is"
 	"github.com/livebud/bud/package/budfs/treefs"
 	"github.com/livebud/bud/package/virtual"
+	"golang.org/x/sync/errgroup"
 )
 
 type generator struct{ label string }
@@ -229,3 +234,224 @@ func TestGenerate(t *testing.T) {
 	err := fstest.TestFS(n, "bud/node_modules/runtime")
 	is.NoErr(err)
 }
+
+type ctxKey struct{}
+
+type ctxGenerator struct{}
+
+func (g *ctxGenerator) Generate(target string) (fs.File, error) {
+	return g.GenerateContext(context.Background(), target)
+}
+
+func (g *ctxGenerator) GenerateContext(ctx context.Context, target string) (fs.File, error) {
+	value, _ := ctx.Value(ctxKey{}).(string)
+	return virtual.New(&virtual.File{Path: target, Data: []byte(value)}), nil
+}
+
+func TestOpenContext(t *testing.T) {
+	is := is.New(t)
+	n := treefs.New(".")
+	n.FileGenerator("a.txt", &ctxGenerator{})
+	ctx := context.WithValue(context.Background(), ctxKey{}, "a")
+	file, err := n.OpenContext(ctx, "a.txt")
+	is.NoErr(err)
+	data, err := io.ReadAll(file)
+	is.NoErr(err)
+	is.Equal(string(data), "a")
+	file, err = n.Open("a.txt")
+	is.NoErr(err)
+	data, err = io.ReadAll(file)
+	is.NoErr(err)
+	is.Equal(string(data), "")
+}
+
+func TestWalk(t *testing.T) {
+	is := is.New(t)
+	n := treefs.New(".")
+	n.FileGenerator("a", ag)
+	n.FileGenerator("b/c/e", eg)
+	n.DirGenerator("b/c", cg)
+	var paths []string
+	var generators []string
+	n.Walk(func(node *treefs.Node) bool {
+		paths = append(paths, node.Path())
+		if generator, ok := node.Generator(); ok {
+			generators = append(generators, fmt.Sprintf("%s", generator))
+		}
+		return true
+	})
+	is.Equal(paths, []string{".", "a", "b", "b/c", "b/c/e"})
+	is.Equal(generators, []string{"a", "c", "e"})
+	// Skip children
+	paths = paths[:0]
+	n.Walk(func(node *treefs.Node) bool {
+		paths = append(paths, node.Path())
+		return node.Path() != "b"
+	})
+	is.Equal(paths, []string{".", "a", "b"})
+}
+
+func TestSetVisible(t *testing.T) {
+	is := is.New(t)
+	n := treefs.New(".")
+	file := func(data string) treefs.Generate {
+		return func(target string) (fs.File, error) {
+			return virtual.New(&virtual.File{Path: target, Data: []byte(data)}), nil
+		}
+	}
+	n.FileGenerator("bud/view.txt", file("view"))
+	helper := n.FileGenerator("bud/helper.txt", file("helper"))
+	n.FileGenerator("bud/"+treefs.InternalDir+"/cache.txt", file("cache"))
+	helper.SetVisible(false)
+	is.True(!helper.Visible())
+	des, err := fs.ReadDir(n, "bud")
+	is.NoErr(err)
+	is.Equal(len(des), 1)
+	is.Equal(des[0].Name(), "view.txt")
+	bud, ok := n.Find("bud")
+	is.True(ok)
+	is.Equal(len(bud.Entries()), 1)
+	// Hidden nodes can still be opened
+	data, err := fs.ReadFile(n, "bud/helper.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "helper")
+	data, err = fs.ReadFile(n, "bud/"+treefs.InternalDir+"/cache.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "cache")
+	// Nodes can be shown again
+	helper.SetVisible(true)
+	des, err = fs.ReadDir(n, "bud")
+	is.NoErr(err)
+	is.Equal(len(des), 2)
+}
+
+func TestReplace(t *testing.T) {
+	is := is.New(t)
+	n := treefs.New(".")
+	file := func(data string) treefs.Generate {
+		return func(target string) (fs.File, error) {
+			return virtual.New(&virtual.File{Path: target, Data: []byte(data)}), nil
+		}
+	}
+	n.FileGenerator("bud/view.txt", file("old"))
+	data, err := fs.ReadFile(n, "bud/view.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "old")
+	err = n.Replace("bud/view.txt", file("new"))
+	is.NoErr(err)
+	data, err = fs.ReadFile(n, "bud/view.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "new")
+	// Missing generators
+	err = n.Replace("bud/missing.txt", file("missing"))
+	is.True(errors.Is(err, fs.ErrNotExist))
+	// Filler directories aren't generators
+	err = n.Replace("bud", file("bud"))
+	is.True(errors.Is(err, fs.ErrNotExist))
+}
+
+func TestMove(t *testing.T) {
+	is := is.New(t)
+	n := treefs.New(".")
+	n.FileGenerator("a", ag)
+	bn := n.DirGenerator("b", bg)
+	cn := bn.DirGenerator("c", cg)
+	cn.FileGenerator("e", eg)
+	is.NoErr(n.Move("b/c", "d/c"))
+	is.Equal(cn.Path(), "d/c")
+	expect := `. mode=d---------
+├── a generator=a mode=----------
+├── b generator=b mode=d---------
+└── d mode=d---------
+    └── c generator=c mode=d---------
+        └── e generator=e mode=----------
+`
+	is.Equal(n.Print(), expect)
+	en, ok := n.Find("d/c/e")
+	is.True(ok)
+	is.Equal(en.Path(), "d/c/e")
+	// Missing source
+	err := n.Move("b/c", "f")
+	is.True(errors.Is(err, fs.ErrNotExist))
+	// Existing destination
+	err = n.Move("a", "d/c")
+	is.True(errors.Is(err, fs.ErrExist))
+	// Moving into itself
+	err = n.Move("d", "d/c/g")
+	is.True(errors.Is(err, fs.ErrInvalid))
+}
+
+func TestPrune(t *testing.T) {
+	is := is.New(t)
+	n := treefs.New(".")
+	n.FileGenerator("a/b/c", ag)
+	n.FileGenerator("d/e", bg)
+	is.NoErr(n.Move("a/b/c", "d/c"))
+	is.Equal(n.Prune(), 2)
+	expect := `. mode=d---------
+└── d mode=d---------
+    ├── c generator=a mode=----------
+    └── e generator=b mode=----------
+`
+	is.Equal(n.Print(), expect)
+	is.Equal(n.Prune(), 0)
+}
+
+func TestMkdirAll(t *testing.T) {
+	is := is.New(t)
+	n := treefs.New(".")
+	n.FileGenerator("a/b", ag)
+	cn := n.MkdirAll("a/c/d")
+	is.Equal(cn.Path(), "a/c/d")
+	is.Equal(n.MkdirAll("a/c/d"), cn)
+	is.Equal(n.MkdirAll("."), n)
+	expect := `. mode=d---------
+└── a mode=d---------
+    ├── b generator=a mode=----------
+    └── c mode=d---------
+        └── d mode=d---------
+`
+	is.Equal(n.Print(), expect)
+}
+
+// registerGenerator registers a file generator within its directory each time
+// the directory is generated
+type registerGenerator struct {
+	node *treefs.Node
+	n    int32
+}
+
+func (g *registerGenerator) Generate(target string) (fs.File, error) {
+	name := fmt.Sprintf("%d.txt", atomic.AddInt32(&g.n, 1))
+	g.node.FileGenerator(name, treefs.Generate(func(target string) (fs.File, error) {
+		return virtual.New(&virtual.File{Path: target}), nil
+	}))
+	return virtual.New(&virtual.Dir{Path: target, Mode: fs.ModeDir, Entries: g.node.Entries()}), nil
+}
+
+func TestConcurrentReadDir(t *testing.T) {
+	is := is.New(t)
+	n := treefs.New(".")
+	dirg := &registerGenerator{}
+	dirg.node = n.DirGenerator("bud/view", dirg)
+	eg := new(errgroup.Group)
+	for i := 0; i < 20; i++ {
+		i := i
+		eg.Go(func() error {
+			_, err := fs.ReadDir(n, "bud/view")
+			return err
+		})
+		eg.Go(func() error {
+			_, err := fs.ReadDir(n, "bud")
+			return err
+		})
+		eg.Go(func() error {
+			n.FileGenerator(fmt.Sprintf("bud/%d.txt", i), ag)
+			return nil
+		})
+	}
+	is.NoErr(eg.Wait())
+	des, err := fs.ReadDir(n, "bud/view")
+	is.NoErr(err)
+	is.True(len(des) >= 21)
+}
@@ -0,0 +1,117 @@
+package budfs
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/livebud/bud/package/virtual"
+)
+
+// DefaultTransactionLogSize is the number of entries kept by the transaction
+// log unless it's resized with SetTransactionLogSize
+const DefaultTransactionLogSize = 1000
+
+// TransactionEntry is an event recorded by the transaction log. Op is one of
+// "change", "generate", "cache_hit" or "cache_miss".
+type TransactionEntry struct {
+	Time time.Time
+	Op   string
+	Path string
+}
+
+// transactionLog is a ring buffer of the most recent entries
+type transactionLog struct {
+	enabled int32
+	mu      sync.Mutex
+	entries []TransactionEntry
+	next    int  // Index of the next entry to write
+	full    bool // True once the buffer has wrapped around
+	clock   func() time.Time
+}
+
+func newTransactionLog(size int) *transactionLog {
+	return &transactionLog{entries: make([]TransactionEntry, size), clock: time.Now}
+}
+
+func (l *transactionLog) record(op, path string) {
+	if atomic.LoadInt32(&l.enabled) == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) == 0 {
+		return
+	}
+	l.entries[l.next] = TransactionEntry{l.clock(), op, path}
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// list returns the entries from oldest to newest
+func (l *transactionLog) list() []TransactionEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.full {
+		return append([]TransactionEntry(nil), l.entries[:l.next]...)
+	}
+	entries := make([]TransactionEntry, 0, len(l.entries))
+	entries = append(entries, l.entries[l.next:]...)
+	return append(entries, l.entries[:l.next]...)
+}
+
+func (l *transactionLog) resize(size int) {
+	entries := l.list()
+	if len(entries) > size {
+		entries = entries[len(entries)-size:]
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = make([]TransactionEntry, size)
+	l.next = copy(l.entries, entries)
+	l.full = false
+	if size > 0 && l.next == size {
+		l.next = 0
+		l.full = true
+	}
+}
+
+// EnableTransactionLog starts recording changes, generator runs and cache
+// lookups. Use TransactionLog to debug why a generated file is stale.
+func (f *FileSystem) EnableTransactionLog() {
+	atomic.StoreInt32(&f.root.tlog.enabled, 1)
+}
+
+// SetTransactionLogSize changes the number of entries the transaction log
+// keeps, discarding the oldest entries if needed
+func (f *FileSystem) SetTransactionLogSize(size int) {
+	if size < 0 {
+		size = 0
+	}
+	f.root.tlog.resize(size)
+}
+
+// TransactionLog returns the most recent entries, oldest first. The log is
+// empty unless EnableTransactionLog has been called.
+func (f *FileSystem) TransactionLog() []TransactionEntry {
+	return f.root.tlog.list()
+}
+
+// cacheGet gets the path from the cache, recording the hit or miss
+func (f *FileSystem) cacheGet(path string) (virtual.Entry, bool) {
+	entry, ok := f.cache.Get(path)
+	if ok {
+		f.tlog.record("cache_hit", path)
+	} else {
+		f.tlog.record("cache_miss", path)
+	}
+	return entry, ok
+}
+
+// generated counts and records a generator run
+func (f *FileSystem) generated(path string) {
+	atomic.AddInt64(&f.generates, 1)
+	f.tlog.record("generate", path)
+}
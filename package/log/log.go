@@ -48,6 +48,47 @@ type Interface interface {
 	Notice(message string, args ...interface{})
 	Warn(message string, args ...interface{})
 	Error(message string, args ...interface{})
+	WithFields(fields ...interface{}) Interface
+}
+
+// WithFields wraps a logger, appending the key-value fields to the arguments
+// of every log call. This is a fallback for loggers that don't support fields
+// natively.
+func WithFields(log Interface, fields ...interface{}) Interface {
+	return &fieldLogger{log, fields}
+}
+
+type fieldLogger struct {
+	log    Interface
+	fields []interface{}
+}
+
+func (l *fieldLogger) args(args []interface{}) []interface{} {
+	return append(args[:len(args):len(args)], l.fields...)
+}
+
+func (l *fieldLogger) Debug(message string, args ...interface{}) {
+	l.log.Debug(message, l.args(args)...)
+}
+
+func (l *fieldLogger) Info(message string, args ...interface{}) {
+	l.log.Info(message, l.args(args)...)
+}
+
+func (l *fieldLogger) Notice(message string, args ...interface{}) {
+	l.log.Notice(message, l.args(args)...)
+}
+
+func (l *fieldLogger) Warn(message string, args ...interface{}) {
+	l.log.Warn(message, l.args(args)...)
+}
+
+func (l *fieldLogger) Error(message string, args ...interface{}) {
+	l.log.Error(message, l.args(args)...)
+}
+
+func (l *fieldLogger) WithFields(fields ...interface{}) Interface {
+	return &fieldLogger{l.log, append(l.fields[:len(l.fields):len(l.fields)], fields...)}
 }
 
 type dispatcher func(log Entry)
@@ -101,8 +142,19 @@ func (l *logger) path() string {
 	return filepath.Dir(filename)
 }
 
-// Turns a list of key values into an array of fields
+// Turns a list of key values into an array of fields, including the logger's
+// own fields
 func (l *logger) keyValues(kvs ...interface{}) (list Fields) {
+	list = append(toFields(kvs...), l.fields...)
+	if len(list) == 0 {
+		return nil
+	}
+	// Sort the fields by key
+	sort.Sort(list)
+	return list
+}
+
+func toFields(kvs ...interface{}) (list Fields) {
 	size := len(kvs)
 	// Special cases
 	if size == 0 {
@@ -116,19 +168,20 @@ func (l *logger) keyValues(kvs ...interface{}) (list Fields) {
 			Value: fmt.Sprintf("%v", kvs[i]),
 		})
 	}
-	// Add in the fields
-	list = append(list, l.fields...)
-	// Sort the fields by key
-	sort.Sort(list)
 	return list
 }
 
 // New sub logger
 func (l *logger) New(fields ...interface{}) Interface {
+	return l.WithFields(fields...)
+}
+
+// WithFields returns a sub logger that includes fields in every entry
+func (l *logger) WithFields(fields ...interface{}) Interface {
 	return &logger{
 		Handler:     l.Handler,
 		includePath: l.includePath,
-		fields:      append(l.keyValues(fields...), l.fields...),
+		fields:      append(toFields(fields...), l.fields...),
 	}
 }
 
@@ -1,7 +1,55 @@
 package log_test
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/livebud/bud/internal/is"
+	"github.com/livebud/bud/package/log"
+)
 
 func TestTime(t *testing.T) {
 	// defer timer.Debug("TestTime(%s)", t).Error(&err)
 }
+
+type handlerFunc func(entry log.Entry)
+
+func (fn handlerFunc) Log(entry log.Entry) {
+	fn(entry)
+}
+
+func TestWithFields(t *testing.T) {
+	is := is.New(t)
+	var entries []log.Entry
+	logger := log.New(handlerFunc(func(entry log.Entry) {
+		entries = append(entries, entry)
+	}))
+	sub := logger.WithFields("module", "app", "generator", "bud/view")
+	sub.Info("generated")
+	sub.Debug("generated", "path", "index.svelte")
+	is.Equal(len(entries), 2)
+	is.Equal(entries[0].Message, "generated")
+	is.Equal(len(entries[0].Fields), 2)
+	is.Equal(entries[0].Fields[0], log.Field{Key: "generator", Value: "bud/view"})
+	is.Equal(entries[0].Fields[1], log.Field{Key: "module", Value: "app"})
+	is.Equal(len(entries[1].Fields), 3)
+	is.Equal(entries[1].Fields[2], log.Field{Key: "path", Value: "index.svelte"})
+	// Parent logger is unaffected
+	logger.Info("plain")
+	is.Equal(len(entries[2].Fields), 0)
+}
+
+func TestWithFieldsFallback(t *testing.T) {
+	is := is.New(t)
+	var entries []log.Entry
+	logger := log.New(handlerFunc(func(entry log.Entry) {
+		entries = append(entries, entry)
+	}))
+	sub := log.WithFields(logger, "module", "app").WithFields("generator", "bud/view")
+	sub.Warn("generated", "path", "index.svelte")
+	is.Equal(len(entries), 1)
+	is.Equal(entries[0].Level, log.WarnLevel)
+	is.Equal(len(entries[0].Fields), 3)
+	is.Equal(entries[0].Fields[0], log.Field{Key: "generator", Value: "bud/view"})
+	is.Equal(entries[0].Fields[1], log.Field{Key: "module", Value: "app"})
+	is.Equal(entries[0].Fields[2], log.Field{Key: "path", Value: "index.svelte"})
+}
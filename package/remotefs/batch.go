@@ -0,0 +1,147 @@
+package remotefs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/livebud/bud/package/virtual"
+)
+
+// Batch collects the operations added in fn and sends them to the server in a
+// single round-trip. The handles returned by the builder are resolved once
+// Batch returns.
+func (c *Client) Batch(ctx context.Context, fn func(b *BatchBuilder)) error {
+	b := new(BatchBuilder)
+	fn(b)
+	if len(b.handles) == 0 {
+		return nil
+	}
+	ops := make([]BatchOp, len(b.handles))
+	for i, h := range b.handles {
+		ops[i] = BatchOp{Op: h.op, Name: h.name}
+	}
+	results := new([]BatchResult)
+	if err := c.rpc.Call(ctx, "remotefs.Batch", ops, results); err != nil {
+		err = fmt.Errorf("remotefs: unable to send batch. %w", err)
+		for _, h := range b.handles {
+			h.resolve(BatchResult{}, err)
+		}
+		return err
+	}
+	if len(*results) != len(ops) {
+		err := fmt.Errorf("remotefs: expected %d batch results but got %d", len(ops), len(*results))
+		for _, h := range b.handles {
+			h.resolve(BatchResult{}, err)
+		}
+		return err
+	}
+	for i, h := range b.handles {
+		h.resolve((*results)[i], nil)
+	}
+	return nil
+}
+
+// BatchBuilder collects operations to send in a batch
+type BatchBuilder struct {
+	handles []*handle
+}
+
+func (b *BatchBuilder) add(op, name string) *handle {
+	h := &handle{op: op, name: name}
+	b.handles = append(b.handles, h)
+	return h
+}
+
+// Open a file within the batch
+func (b *BatchBuilder) Open(name string) *FileHandle {
+	return &FileHandle{b.add("open", name)}
+}
+
+// Stat a file within the batch
+func (b *BatchBuilder) Stat(name string) *StatHandle {
+	return &StatHandle{b.add("stat", name)}
+}
+
+// ReadFile reads a file within the batch
+func (b *BatchBuilder) ReadFile(name string) *DataHandle {
+	return &DataHandle{b.add("readfile", name)}
+}
+
+// ErrBatchPending is returned when a handle is accessed before its batch has
+// been sent
+var ErrBatchPending = errors.New("remotefs: batch not yet sent")
+
+type handle struct {
+	op       string
+	name     string
+	resolved bool
+	result   BatchResult
+	err      error
+}
+
+func (h *handle) resolve(result BatchResult, err error) {
+	h.resolved = true
+	h.result = result
+	h.err = err
+}
+
+func (h *handle) Err() error {
+	if !h.resolved {
+		return ErrBatchPending
+	}
+	if h.err != nil {
+		return h.err
+	}
+	if h.result.Err != "" {
+		if isNotExist(errors.New(h.result.Err)) {
+			return &fs.PathError{Op: h.op, Path: h.name, Err: fs.ErrNotExist}
+		}
+		return errors.New(h.result.Err)
+	}
+	return nil
+}
+
+// FileHandle resolves to an opened file
+type FileHandle struct {
+	*handle
+}
+
+// File returns the opened file
+func (h *FileHandle) File() (fs.File, error) {
+	if err := h.Err(); err != nil {
+		return nil, err
+	}
+	return virtual.New(h.result.Entry), nil
+}
+
+// StatHandle resolves to the file info
+type StatHandle struct {
+	*handle
+}
+
+// Info returns the file info
+func (h *StatHandle) Info() (fs.FileInfo, error) {
+	if err := h.Err(); err != nil {
+		return nil, err
+	}
+	return h.result.Stat.Info()
+}
+
+// DataHandle resolves to the file's data
+type DataHandle struct {
+	*handle
+}
+
+// Data returns the file's data
+func (h *DataHandle) Data() ([]byte, error) {
+	if err := h.Err(); err != nil {
+		return nil, err
+	}
+	file, ok := h.result.Entry.(*virtual.File)
+	if !ok || file.Mode.IsDir() {
+		return nil, &fs.PathError{Op: h.op, Path: h.name, Err: errors.New("is a directory")}
+	}
+	return file.Data, nil
+}
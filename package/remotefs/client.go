@@ -3,6 +3,8 @@ package remotefs
 import (
 	"context"
 	"encoding/gob"
+	"fmt"
+	"io"
 	"io/fs"
 	"strings"
 
@@ -22,7 +24,13 @@ func Dial(ctx context.Context, addr string) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return NewClient(rpc.NewClient(conn)), nil
+	return NewClientFromConn(conn), nil
+}
+
+// NewClientFromConn creates a client that talks to a server over any
+// connection, such as one end of net.Pipe or a subprocess's stdin and stdout.
+func NewClientFromConn(conn io.ReadWriteCloser) *Client {
+	return NewClient(rpc.NewClient(conn))
 }
 
 func NewClient(rpc *rpc.Client) *Client {
@@ -64,6 +72,17 @@ func (c *Client) ReadDir(name string) (des []fs.DirEntry, err error) {
 	return *vdes, nil
 }
 
+// Ping checks that the server is responding
+func (c *Client) Ping(ctx context.Context) error {
+	reply := new(string)
+	if err := c.rpc.Call(ctx, "remotefs.Ping", "ping", reply); err != nil {
+		return fmt.Errorf("remotefs: unable to ping. %w", err)
+	} else if *reply != "ping" {
+		return fmt.Errorf("remotefs: unexpected ping reply %q", *reply)
+	}
+	return nil
+}
+
 func (c *Client) Close() error {
 	return c.rpc.Close()
 }
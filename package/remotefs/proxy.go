@@ -0,0 +1,131 @@
+package remotefs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"sync/atomic"
+)
+
+// RoutingPolicy decides which client a proxy tries first
+type RoutingPolicy int
+
+const (
+	// FirstAvailable tries the clients in order
+	FirstAvailable RoutingPolicy = iota
+	// RoundRobin rotates the first client tried on every call
+	RoundRobin
+)
+
+// NewProxy creates a proxy that routes reads across clients serving the same
+// filesystem. When a client fails, the proxy falls back to the next client.
+func NewProxy(clients []*Client, policy RoutingPolicy) *Proxy {
+	return &Proxy{
+		clients:   clients,
+		policy:    policy,
+		unhealthy: make([]bool, len(clients)),
+	}
+}
+
+// Proxy fans out reads across multiple remote filesystems. Proxy is safe for
+// concurrent use.
+type Proxy struct {
+	clients []*Client
+	policy  RoutingPolicy
+	next    uint64 // Next client for round-robin routing
+
+	mu        sync.RWMutex
+	unhealthy []bool // Set by HealthCheck
+}
+
+var _ fs.FS = (*Proxy)(nil)
+var _ fs.ReadDirFS = (*Proxy)(nil)
+
+func (p *Proxy) Open(name string) (file fs.File, err error) {
+	err = p.route(func(client *Client) (err error) {
+		file, err = client.Open(name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (p *Proxy) ReadDir(name string) (des []fs.DirEntry, err error) {
+	err = p.route(func(client *Client) (err error) {
+		des, err = client.ReadDir(name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return des, nil
+}
+
+// HealthCheck pings all the clients concurrently, marking the clients that
+// don't respond as unhealthy. Unhealthy clients are only tried after the
+// healthy clients fail. Returns an error if none of the clients responded.
+func (p *Proxy) HealthCheck(ctx context.Context) error {
+	unhealthy := make([]bool, len(p.clients))
+	var wg sync.WaitGroup
+	for i, client := range p.clients {
+		wg.Add(1)
+		go func(i int, client *Client) {
+			defer wg.Done()
+			unhealthy[i] = client.Ping(ctx) != nil
+		}(i, client)
+	}
+	wg.Wait()
+	p.mu.Lock()
+	p.unhealthy = unhealthy
+	p.mu.Unlock()
+	for _, bad := range unhealthy {
+		if !bad {
+			return nil
+		}
+	}
+	return fmt.Errorf("remotefs: none of the %d clients are healthy", len(p.clients))
+}
+
+// route calls fn with each client in the order decided by the policy until
+// one succeeds. Missing files aren't retried since every client serves the
+// same filesystem.
+func (p *Proxy) route(fn func(client *Client) error) error {
+	if len(p.clients) == 0 {
+		return fmt.Errorf("remotefs: proxy has no clients")
+	}
+	var err error
+	for _, i := range p.order() {
+		if err = fn(p.clients[i]); err == nil {
+			return nil
+		} else if errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return fmt.Errorf("remotefs: all clients failed. %w", err)
+}
+
+// order returns the client indexes to try, with healthy clients first
+func (p *Proxy) order() []int {
+	n := len(p.clients)
+	start := 0
+	if p.policy == RoundRobin {
+		start = int((atomic.AddUint64(&p.next, 1) - 1) % uint64(n))
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	healthy := make([]int, 0, n)
+	var unhealthy []int
+	for j := 0; j < n; j++ {
+		i := (start + j) % n
+		if p.unhealthy[i] {
+			unhealthy = append(unhealthy, i)
+			continue
+		}
+		healthy = append(healthy, i)
+	}
+	return append(healthy, unhealthy...)
+}
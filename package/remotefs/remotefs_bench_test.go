@@ -0,0 +1,109 @@
+package remotefs_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/livebud/bud/package/remotefs"
+	"github.com/livebud/bud/package/socket"
+)
+
+// benchFS has a 4KB file and a directory with 100 entries
+func benchFS() fstest.MapFS {
+	fsys := fstest.MapFS{
+		"main.js": &fstest.MapFile{Data: bytes.Repeat([]byte("a"), 4096)},
+	}
+	for i := 0; i < 100; i++ {
+		fsys[fmt.Sprintf("view/%d.svelte", i)] = &fstest.MapFile{Data: []byte("<h1>view</h1>")}
+	}
+	return fsys
+}
+
+// benchClient serves fsys over the address and returns a connected client
+func benchClient(b *testing.B, address string) *remotefs.Client {
+	b.Helper()
+	ln, err := socket.Listen(address)
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- remotefs.NewServer(benchFS()).ServeContext(ctx, ln) }()
+	client, err := remotefs.Dial(ctx, ln.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		client.Close()
+		cancel()
+		<-served
+	})
+	return client
+}
+
+func tcpClient(b *testing.B) *remotefs.Client {
+	return benchClient(b, "127.0.0.1:0")
+}
+
+func unixClient(b *testing.B) *remotefs.Client {
+	return benchClient(b, filepath.Join(b.TempDir(), "bench.sock"))
+}
+
+// Benchmarks run in parallel to expose contention on the shared connection, so
+// failures are reported with b.Error rather than b.Fatal
+func benchmarkOpen(b *testing.B, client *remotefs.Client) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			file, err := client.Open("main.js")
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			file.Close()
+		}
+	})
+}
+
+func BenchmarkClientOpen_TCP(b *testing.B) {
+	benchmarkOpen(b, tcpClient(b))
+}
+
+func BenchmarkClientOpen_Unix(b *testing.B) {
+	benchmarkOpen(b, unixClient(b))
+}
+
+func BenchmarkClientReadDir_TCP(b *testing.B) {
+	client := tcpClient(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := client.ReadDir("view"); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkClientReadFile_TCP(b *testing.B) {
+	client := tcpClient(b)
+	b.SetBytes(4096)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := fs.ReadFile(client, "main.js"); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
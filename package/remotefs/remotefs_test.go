@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"testing"
 	"testing/fstest"
+	"time"
 
 	"github.com/livebud/bud/internal/is"
 	"github.com/livebud/bud/internal/testsub"
@@ -144,3 +145,245 @@ func TestCommand(t *testing.T) {
 	}
 	testsub.Run(t, parent, child)
 }
+
+// blockingFS blocks opening files until released
+type blockingFS struct {
+	fs.FS
+	opened  chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingFS) Open(name string) (fs.File, error) {
+	if name == "slow.txt" {
+		close(b.opened)
+		<-b.release
+	}
+	return b.FS.Open(name)
+}
+
+func TestServeContext(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := listen(t)
+	is.NoErr(err)
+	defer ln.Close()
+	fsys := &blockingFS{
+		FS: fstest.MapFS{
+			"slow.txt": &fstest.MapFile{Data: []byte("slow")},
+		},
+		opened:  make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	client, err := remotefs.Dial(ctx, ln.Addr().String())
+	is.NoErr(err)
+	defer client.Close()
+	served := make(chan error, 1)
+	go func() { served <- remotefs.NewServer(fsys).ServeContext(ctx, ln) }()
+	read := make(chan []byte, 1)
+	go func() {
+		data, err := fs.ReadFile(client, "slow.txt")
+		is.NoErr(err)
+		read <- data
+	}()
+	<-fsys.opened
+	cancel()
+	// New connections are refused
+	_, err = remotefs.Dial(context.Background(), ln.Addr().String())
+	for i := 0; err == nil && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		_, err = remotefs.Dial(context.Background(), ln.Addr().String())
+	}
+	is.True(err != nil)
+	// Server waits for the in-flight request
+	select {
+	case <-served:
+		t.Fatal("server returned before the in-flight request completed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(fsys.release)
+	is.Equal(string(<-read), "slow")
+	is.NoErr(<-served)
+}
+
+func TestServeContextGracePeriod(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := listen(t)
+	is.NoErr(err)
+	defer ln.Close()
+	fsys := &blockingFS{
+		FS: fstest.MapFS{
+			"slow.txt": &fstest.MapFile{Data: []byte("slow")},
+		},
+		opened:  make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	client, err := remotefs.Dial(ctx, ln.Addr().String())
+	is.NoErr(err)
+	defer client.Close()
+	server := remotefs.NewServer(fsys)
+	server.GracePeriod = 10 * time.Millisecond
+	served := make(chan error, 1)
+	go func() { served <- server.ServeContext(ctx, ln) }()
+	go fs.ReadFile(client, "slow.txt")
+	<-fsys.opened
+	cancel()
+	// The handler is stuck, so the server stops waiting after the grace period
+	// and returns once the handler exits
+	time.Sleep(50 * time.Millisecond)
+	close(fsys.release)
+	is.NoErr(<-served)
+}
+
+func TestBatch(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	server, err := listen(t)
+	is.NoErr(err)
+	defer server.Close()
+	client, err := remotefs.Dial(ctx, server.Addr().String())
+	is.NoErr(err)
+	fsys := fstest.MapFS{
+		"view/index.svelte": &fstest.MapFile{Data: []byte("<h1>index</h1>")},
+		"view/about.svelte": &fstest.MapFile{Data: []byte("<h1>about</h1>")},
+	}
+	go remotefs.Serve(fsys, server)
+	var index *remotefs.DataHandle
+	var about *remotefs.StatHandle
+	var view *remotefs.FileHandle
+	var missing *remotefs.DataHandle
+	err = client.Batch(ctx, func(b *remotefs.BatchBuilder) {
+		index = b.ReadFile("view/index.svelte")
+		about = b.Stat("view/about.svelte")
+		view = b.Open("view")
+		missing = b.ReadFile("view/missing.svelte")
+		// Handles aren't resolved until the batch is sent
+		_, err := index.Data()
+		is.True(errors.Is(err, remotefs.ErrBatchPending))
+	})
+	is.NoErr(err)
+	data, err := index.Data()
+	is.NoErr(err)
+	is.Equal(string(data), "<h1>index</h1>")
+	stat, err := about.Info()
+	is.NoErr(err)
+	is.Equal(stat.Name(), "about.svelte")
+	is.Equal(stat.Size(), int64(14))
+	dir, err := view.File()
+	is.NoErr(err)
+	des, err := dir.(fs.ReadDirFile).ReadDir(-1)
+	is.NoErr(err)
+	is.Equal(len(des), 2)
+	data, err = missing.Data()
+	is.True(errors.Is(err, fs.ErrNotExist))
+	is.Equal(data, nil)
+}
+
+func TestPipe(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fsys := fstest.MapFS{
+		"a.txt":   &fstest.MapFile{Data: []byte("a")},
+		"b/b.txt": &fstest.MapFile{Data: []byte("b")},
+	}
+	serverConn, clientConn := net.Pipe()
+	server := remotefs.NewServerFromConn(fsys, serverConn)
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(ctx) }()
+	client := remotefs.NewClientFromConn(clientConn)
+	data, err := fs.ReadFile(client, "a.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "a")
+	des, err := fs.ReadDir(client, "b")
+	is.NoErr(err)
+	is.Equal(len(des), 1)
+	is.Equal(des[0].Name(), "b.txt")
+	_, err = fs.ReadFile(client, "c.txt")
+	is.True(errors.Is(err, fs.ErrNotExist))
+	// Closing the client stops the server
+	is.NoErr(client.Close())
+	is.NoErr(<-served)
+}
+
+func TestPipeCancel(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	server := remotefs.NewServerFromConn(fstest.MapFS{}, serverConn)
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(ctx) }()
+	cancel()
+	is.NoErr(<-served)
+	// Serving without a connection fails
+	err := remotefs.NewServer(fstest.MapFS{}).Serve(context.Background())
+	is.True(err != nil)
+}
+
+func pipeClient(t testing.TB, fsys fs.FS) *remotefs.Client {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	serverConn, clientConn := net.Pipe()
+	server := remotefs.NewServerFromConn(fsys, serverConn)
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		<-served
+	})
+	return remotefs.NewClientFromConn(clientConn)
+}
+
+func TestProxyFirstAvailable(t *testing.T) {
+	is := is.New(t)
+	client1 := pipeClient(t, fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("1")}})
+	client2 := pipeClient(t, fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("2")}})
+	proxy := remotefs.NewProxy([]*remotefs.Client{client1, client2}, remotefs.FirstAvailable)
+	for i := 0; i < 2; i++ {
+		data, err := fs.ReadFile(proxy, "a.txt")
+		is.NoErr(err)
+		is.Equal(string(data), "1")
+	}
+	des, err := fs.ReadDir(proxy, ".")
+	is.NoErr(err)
+	is.Equal(len(des), 1)
+	_, err = fs.ReadFile(proxy, "b.txt")
+	is.True(errors.Is(err, fs.ErrNotExist))
+	// Fall back to the next client
+	is.NoErr(client1.Close())
+	data, err := fs.ReadFile(proxy, "a.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "2")
+	is.NoErr(proxy.HealthCheck(context.Background()))
+	// Fail when every client fails
+	is.NoErr(client2.Close())
+	_, err = fs.ReadFile(proxy, "a.txt")
+	is.True(err != nil)
+	is.True(proxy.HealthCheck(context.Background()) != nil)
+}
+
+func TestProxyRoundRobin(t *testing.T) {
+	is := is.New(t)
+	client1 := pipeClient(t, fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("1")}})
+	client2 := pipeClient(t, fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("2")}})
+	proxy := remotefs.NewProxy([]*remotefs.Client{client1, client2}, remotefs.RoundRobin)
+	is.NoErr(proxy.HealthCheck(context.Background()))
+	var results []string
+	for i := 0; i < 4; i++ {
+		data, err := fs.ReadFile(proxy, "a.txt")
+		is.NoErr(err)
+		results = append(results, string(data))
+	}
+	is.Equal(results, []string{"1", "2", "1", "2"})
+	// Unhealthy clients are skipped
+	is.NoErr(client2.Close())
+	is.NoErr(proxy.HealthCheck(context.Background()))
+	for i := 0; i < 2; i++ {
+		data, err := fs.ReadFile(proxy, "a.txt")
+		is.NoErr(err)
+		is.Equal(string(data), "1")
+	}
+}
@@ -1,12 +1,17 @@
 package remotefs
 
 import (
+	"bufio"
 	"context"
+	"encoding/gob"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"net"
 	"net/rpc"
+	"sync"
+	"time"
 
 	"github.com/livebud/bud/internal/extrafile"
 	"github.com/livebud/bud/package/socket"
@@ -26,9 +31,7 @@ func ServeFrom(ctx context.Context, fsys fs.FS, prefix string) error {
 		return fmt.Errorf("remotefs: unable to turn extra file into listener. %w", err)
 	}
 	defer ln.Close()
-	go Serve(fsys, ln)
-	<-ctx.Done()
-	return nil
+	return NewServer(fsys).ServeContext(ctx, ln)
 }
 
 // Serve the filesystem from a listener
@@ -52,3 +55,237 @@ func accept(server *rpc.Server, ln net.Listener) error {
 		go server.ServeConn(conn)
 	}
 }
+
+// defaultGracePeriod is how long the server waits for in-flight requests by
+// default
+const defaultGracePeriod = 5 * time.Second
+
+// NewServer creates a server for the filesystem
+func NewServer(fsys fs.FS) *Server {
+	return &Server{GracePeriod: defaultGracePeriod, fsys: fsys}
+}
+
+// NewServerFromConn creates a server for the filesystem that serves a single
+// connection, such as one end of net.Pipe or the process's stdin and stdout.
+// Call Serve to start serving.
+func NewServerFromConn(fsys fs.FS, conn io.ReadWriteCloser) *Server {
+	return &Server{GracePeriod: defaultGracePeriod, fsys: fsys, conn: conn}
+}
+
+// Server serves a filesystem and supports graceful shutdown
+type Server struct {
+	// GracePeriod is how long to wait for in-flight requests to complete after
+	// the server starts shutting down
+	GracePeriod time.Duration
+	fsys        fs.FS
+	conn        io.ReadWriteCloser // Set by NewServerFromConn
+}
+
+// Serve the connection passed into NewServerFromConn until the other end
+// closes it or the context is cancelled. Once cancelled, in-flight requests
+// are given the grace period to finish before the connection is closed.
+func (s *Server) Serve(ctx context.Context) error {
+	if s.conn == nil {
+		return fmt.Errorf("remotefs: server wasn't created with a connection")
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("remotefs", NewService(s.fsys)); err != nil {
+		return err
+	}
+	tracker := newTracker()
+	codec := tracker.Track(s.conn)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.ServeCodec(codec)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		tracker.Wait(s.GracePeriod)
+		tracker.Close()
+		<-done
+		return nil
+	}
+}
+
+// ServeContext serves the filesystem from the listener until the context is
+// cancelled or the listener is closed. Once cancelled, the listener is closed
+// immediately and in-flight requests are given the grace period to finish.
+// All goroutines started by the server have exited upon return.
+func (s *Server) ServeContext(ctx context.Context, ln net.Listener) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName("remotefs", NewService(s.fsys)); err != nil {
+		return err
+	}
+	tracker := newTracker()
+	wg := new(sync.WaitGroup)
+	// Close the listener as soon as the context is cancelled
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-ctx.Done():
+			ln.Close()
+		case <-stop:
+		}
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			close(stop)
+			tracker.Wait(s.GracePeriod)
+			tracker.Close()
+			wg.Wait()
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		codec := tracker.Track(conn)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			server.ServeCodec(codec)
+		}()
+	}
+}
+
+func newTracker() *tracker {
+	return &tracker{
+		codecs: map[*serverCodec]struct{}{},
+	}
+}
+
+// tracker keeps track of open connections and in-flight requests
+type tracker struct {
+	mu       sync.Mutex
+	inflight int
+	idle     chan struct{} // closed when there are no more in-flight requests
+	codecs   map[*serverCodec]struct{}
+}
+
+func (t *tracker) Track(conn io.ReadWriteCloser) *serverCodec {
+	buf := bufio.NewWriter(conn)
+	codec := &serverCodec{
+		rwc:     conn,
+		dec:     gob.NewDecoder(conn),
+		enc:     gob.NewEncoder(buf),
+		encBuf:  buf,
+		tracker: t,
+	}
+	t.mu.Lock()
+	t.codecs[codec] = struct{}{}
+	t.mu.Unlock()
+	return codec
+}
+
+func (t *tracker) start() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.inflight == 0 {
+		t.idle = make(chan struct{})
+	}
+	t.inflight++
+}
+
+func (t *tracker) done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inflight--
+	if t.inflight == 0 {
+		close(t.idle)
+	}
+}
+
+func (t *tracker) untrack(codec *serverCodec) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.codecs, codec)
+}
+
+// Wait for the in-flight requests to finish or the grace period to elapse,
+// whichever comes first
+func (t *tracker) Wait(grace time.Duration) {
+	t.mu.Lock()
+	if t.inflight == 0 {
+		t.mu.Unlock()
+		return
+	}
+	idle := t.idle
+	t.mu.Unlock()
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-idle:
+	case <-timer.C:
+	}
+}
+
+// Close all the remaining connections
+func (t *tracker) Close() {
+	t.mu.Lock()
+	codecs := make([]*serverCodec, 0, len(t.codecs))
+	for codec := range t.codecs {
+		codecs = append(codecs, codec)
+	}
+	t.mu.Unlock()
+	for _, codec := range codecs {
+		codec.rwc.Close()
+	}
+}
+
+// serverCodec is a copy of net/rpc's gobServerCodec that reports each request
+// to the tracker
+type serverCodec struct {
+	rwc     io.ReadWriteCloser
+	dec     *gob.Decoder
+	enc     *gob.Encoder
+	encBuf  *bufio.Writer
+	tracker *tracker
+	closed  bool
+}
+
+var _ rpc.ServerCodec = (*serverCodec)(nil)
+
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	if err := c.dec.Decode(r); err != nil {
+		return err
+	}
+	// Every request header that's read successfully gets a response
+	c.tracker.start()
+	return nil
+}
+
+func (c *serverCodec) ReadRequestBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+func (c *serverCodec) WriteResponse(r *rpc.Response, body interface{}) (err error) {
+	defer c.tracker.done()
+	if err = c.enc.Encode(r); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return err
+	}
+	if err = c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *serverCodec) Close() error {
+	if c.closed {
+		// Only call c.rwc.Close once; otherwise the semantics are undefined.
+		return nil
+	}
+	c.closed = true
+	c.tracker.untrack(c)
+	return c.rwc.Close()
+}
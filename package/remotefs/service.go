@@ -1,6 +1,7 @@
 package remotefs
 
 import (
+	"fmt"
 	"io"
 	"io/fs"
 
@@ -84,3 +85,52 @@ func (s *Service) ReadDir(name string, vdes *[]fs.DirEntry) error {
 	}
 	return nil
 }
+
+// BatchOp is a single operation within a batch
+type BatchOp struct {
+	Op   string // "open", "stat" or "readfile"
+	Name string
+}
+
+// BatchResult is the result of a single batch operation. Errors are passed as
+// strings because they're serialized between processes.
+type BatchResult struct {
+	Entry virtual.Entry
+	Stat  *virtual.DirEntry
+	Err   string
+}
+
+// Batch runs multiple operations in a single call
+func (s *Service) Batch(ops []BatchOp, results *[]BatchResult) error {
+	*results = make([]BatchResult, len(ops))
+	for i, op := range ops {
+		result := &(*results)[i]
+		switch op.Op {
+		case "open", "readfile":
+			if err := s.Open(op.Name, &result.Entry); err != nil {
+				result.Err = err.Error()
+			}
+		case "stat":
+			stat, err := fs.Stat(s.fsys, op.Name)
+			if err != nil {
+				result.Err = err.Error()
+				continue
+			}
+			result.Stat = &virtual.DirEntry{
+				Path:    op.Name,
+				Size:    stat.Size(),
+				Mode:    stat.Mode(),
+				ModTime: stat.ModTime(),
+			}
+		default:
+			result.Err = fmt.Sprintf("remotefs: unknown batch operation %q", op.Op)
+		}
+	}
+	return nil
+}
+
+// Ping echoes the message back to check that the server is responding
+func (s *Service) Ping(msg string, reply *string) error {
+	*reply = msg
+	return nil
+}
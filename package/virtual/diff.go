@@ -0,0 +1,111 @@
+package virtual
+
+import (
+	"errors"
+	"io/fs"
+	"sort"
+)
+
+// DiffKind is the kind of change between two filesystems
+type DiffKind uint8
+
+const (
+	// Added files only exist in the new filesystem
+	Added DiffKind = iota + 1
+	// Removed files only exist in the old filesystem
+	Removed
+	// Modified files exist in both filesystems with different contents
+	Modified
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	default:
+		return "unknown"
+	}
+}
+
+// DiffEntry is a file that differs between two filesystems. The hashes are
+// hex-encoded SHA-256 hashes of the file contents. OldHash is empty for added
+// files and NewHash is empty for removed files.
+type DiffEntry struct {
+	Path    string
+	Kind    DiffKind
+	OldHash string
+	NewHash string
+}
+
+// Diff compares the files within root of the old filesystem a and the new
+// filesystem b. Directories are only compared by the files they contain. A
+// root that doesn't exist is treated as empty. The entries are sorted by path.
+func Diff(a, b FS, root string) ([]DiffEntry, error) {
+	// Walk the filesystems one after the other, since filesystems like Tree
+	// and Map aren't safe for concurrent use
+	olds, err := hashFiles(a, root)
+	if err != nil {
+		return nil, err
+	}
+	news, err := hashFiles(b, root)
+	if err != nil {
+		return nil, err
+	}
+	var entries []DiffEntry
+	for path, prev := range olds {
+		next, ok := news[path]
+		if !ok {
+			entries = append(entries, DiffEntry{Path: path, Kind: Removed, OldHash: prev.hash})
+			continue
+		}
+		if prev.size != next.size || prev.hash != next.hash {
+			entries = append(entries, DiffEntry{Path: path, Kind: Modified, OldHash: prev.hash, NewHash: next.hash})
+		}
+	}
+	for path, next := range news {
+		if _, ok := olds[path]; !ok {
+			entries = append(entries, DiffEntry{Path: path, Kind: Added, NewHash: next.hash})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries, nil
+}
+
+type hashedFile struct {
+	size int
+	hash string
+}
+
+// hashFiles hashes every file within root
+func hashFiles(fsys fs.FS, root string) (map[string]hashedFile, error) {
+	files := map[string]hashedFile{}
+	err := fs.WalkDir(fsys, root, func(path string, de fs.DirEntry, err error) error {
+		if err != nil {
+			// Treat a missing root as an empty directory
+			if path == root && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if de.IsDir() {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		file := &File{Data: data}
+		files[path] = hashedFile{len(data), file.Checksum()}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
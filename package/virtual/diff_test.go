@@ -0,0 +1,53 @@
+package virtual_test
+
+import (
+	"testing"
+
+	"github.com/livebud/bud/internal/is"
+	"github.com/livebud/bud/package/virtual"
+)
+
+func TestDiff(t *testing.T) {
+	is := is.New(t)
+	a := virtual.Tree{
+		"bud/view/index.go": &virtual.File{Data: []byte("package view")},
+		"bud/view/about.go": &virtual.File{Data: []byte("package about")},
+		"bud/main.go":       &virtual.File{Data: []byte("package main")},
+		"go.mod":            &virtual.File{Data: []byte("module app.com")},
+	}
+	b := virtual.Tree{
+		"bud/view/index.go":  &virtual.File{Data: []byte("package view")},
+		"bud/view/show.go":   &virtual.File{Data: []byte("package show")},
+		"bud/main.go":        &virtual.File{Data: []byte("package main // changed")},
+		"go.mod":             &virtual.File{Data: []byte("module other.com")},
+		"bud/public/app.css": &virtual.File{Data: []byte("body {}")},
+	}
+	entries, err := virtual.Diff(a, b, "bud")
+	is.NoErr(err)
+	is.Equal(len(entries), 4)
+	is.Equal(entries[0].Path, "bud/main.go")
+	is.Equal(entries[0].Kind, virtual.Modified)
+	is.True(entries[0].OldHash != "")
+	is.True(entries[0].NewHash != "")
+	is.True(entries[0].OldHash != entries[0].NewHash)
+	is.Equal(entries[1].Path, "bud/public/app.css")
+	is.Equal(entries[1].Kind, virtual.Added)
+	is.Equal(entries[1].OldHash, "")
+	is.Equal(entries[2].Path, "bud/view/about.go")
+	is.Equal(entries[2].Kind, virtual.Removed)
+	is.Equal(entries[2].NewHash, "")
+	is.Equal(entries[3].Path, "bud/view/show.go")
+	is.Equal(entries[3].Kind, virtual.Added)
+	is.Equal(entries[3].NewHash, "833ca89a7742f60f97f0137b0696392e3db716388d8e2f74fe03a1dfd9ad5823")
+	// Missing roots are empty
+	entries, err = virtual.Diff(a, virtual.Tree{}, "bud")
+	is.NoErr(err)
+	is.Equal(len(entries), 3)
+	for _, entry := range entries {
+		is.Equal(entry.Kind, virtual.Removed)
+	}
+	// Identical filesystems
+	entries, err = virtual.Diff(a, a, ".")
+	is.NoErr(err)
+	is.Equal(len(entries), 0)
+}
@@ -1,9 +1,15 @@
 package virtual
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"io/fs"
+	"mime"
 	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,6 +19,14 @@ type File struct {
 	Data    []byte
 	Mode    fs.FileMode
 	ModTime time.Time
+
+	sum atomic.Value // *checksum
+}
+
+// checksum caches the SHA-256 hash of the data it was computed from
+type checksum struct {
+	data  []byte
+	value string
 }
 
 var _ fs.DirEntry = (*File)(nil)
@@ -43,6 +57,69 @@ func (f *File) Info() (fs.FileInfo, error) {
 	}, nil
 }
 
+// Checksum returns the hex-encoded SHA-256 hash of the file's data. Diff uses
+// it to compare files. The hash is cached until Data is reassigned, but
+// modifying Data in place requires SetData to reset the cache.
+func (f *File) Checksum() string {
+	if sum, ok := f.sum.Load().(*checksum); ok && sum != nil && sameSlice(sum.data, f.Data) {
+		return sum.value
+	}
+	hash := sha256.Sum256(f.Data)
+	sum := &checksum{f.Data, hex.EncodeToString(hash[:])}
+	f.sum.Store(sum)
+	return sum.value
+}
+
+// SetData replaces the file's data and resets the cached checksum
+func (f *File) SetData(data []byte) {
+	f.Data = data
+	f.sum.Store((*checksum)(nil))
+}
+
+// sameSlice returns true if a and b share the same backing array and length
+func sameSlice(a, b []byte) bool {
+	if len(a) != len(b) || cap(a) != cap(b) {
+		return false
+	}
+	return len(a) == 0 || &a[0] == &b[0]
+}
+
+// ContentType returns the MIME type of the file based on its extension. Types
+// registered with RegisterContentType take precedence. Returns
+// "application/octet-stream" for unknown extensions.
+func (f *File) ContentType() string {
+	ext := strings.ToLower(path.Ext(f.Path))
+	contentTypes.RLock()
+	contentType, ok := contentTypes.m[ext]
+	contentTypes.RUnlock()
+	if ok {
+		return contentType
+	}
+	if contentType := mime.TypeByExtension(ext); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// contentTypes are custom MIME types keyed by extension
+var contentTypes = struct {
+	sync.RWMutex
+	m map[string]string
+}{m: map[string]string{}}
+
+// RegisterContentType registers a custom MIME type for an extension, such as
+// RegisterContentType(".svelte", "text/html"). RegisterContentType is safe for
+// concurrent use.
+func RegisterContentType(ext, mimeType string) {
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	contentTypes.Lock()
+	contentTypes.m[ext] = mimeType
+	contentTypes.Unlock()
+}
+
 func (f *File) open() fs.File {
 	return &entryFile{f, 0}
 }
@@ -0,0 +1,50 @@
+package virtual_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/livebud/bud/internal/is"
+	"github.com/livebud/bud/package/virtual"
+)
+
+func TestContentType(t *testing.T) {
+	is := is.New(t)
+	contentType := func(path string) string {
+		return (&virtual.File{Path: path}).ContentType()
+	}
+	is.True(strings.HasPrefix(contentType("public/main.css"), "text/css"))
+	is.True(strings.HasPrefix(contentType("view/index.html"), "text/html"))
+	is.True(strings.HasPrefix(contentType("public/main.JS"), "text/javascript"))
+	is.Equal(contentType("public/favicon.png"), "image/png")
+	is.Equal(contentType("bud/app"), "application/octet-stream")
+	is.Equal(contentType("view/index.unknownext"), "application/octet-stream")
+	// Custom types take precedence
+	virtual.RegisterContentType("unknownext", "text/x-unknown")
+	is.Equal(contentType("view/index.unknownext"), "text/x-unknown")
+	is.Equal(contentType("view/index.UNKNOWNEXT"), "text/x-unknown")
+}
+
+func TestChecksum(t *testing.T) {
+	is := is.New(t)
+	file := &virtual.File{Path: "a.txt", Data: []byte("a")}
+	is.Equal(file.Checksum(), "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb")
+	is.Equal(file.Checksum(), "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb")
+	file.SetData([]byte("b"))
+	is.Equal(string(file.Data), "b")
+	is.Equal(file.Checksum(), "3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d")
+	// Reassigning Data directly resets the checksum too
+	file.Data = []byte("a")
+	is.Equal(file.Checksum(), "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb")
+	// Copies don't share a stale checksum
+	copied := *file
+	copied.Data = []byte("b")
+	is.Equal(copied.Checksum(), "3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d")
+	is.Equal(file.Checksum(), "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb")
+	// Modifying Data in place requires SetData
+	file.Data[0] = 'b'
+	file.SetData(file.Data)
+	is.Equal(file.Checksum(), "3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d")
+	empty := &virtual.File{Path: "empty.txt"}
+	is.Equal(empty.Checksum(), "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+}
@@ -3,7 +3,6 @@ package virtual
 import (
 	"io/fs"
 	"path"
-	"time"
 )
 
 type Map map[string]*File
@@ -28,13 +27,13 @@ func (m Map) Open(path string) (fs.File, error) {
 
 // Mkdir create a directory.
 func (m Map) MkdirAll(path string, perm fs.FileMode) error {
-	m[path] = &File{path, nil, perm | fs.ModeDir, time.Time{}}
+	m[path] = &File{Path: path, Mode: perm | fs.ModeDir}
 	return nil
 }
 
 // WriteFile writes a file
 func (m Map) WriteFile(path string, data []byte, perm fs.FileMode) error {
-	m[path] = &File{path, data, perm, time.Time{}}
+	m[path] = &File{Path: path, Data: data, Mode: perm}
 	return nil
 }
 
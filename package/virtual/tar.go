@@ -0,0 +1,79 @@
+package virtual
+
+import (
+	"archive/tar"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// NewTarReader streams the files within fsys under root as a tar archive. The
+// archive is generated lazily as it's read and files are copied into the
+// stream without being fully buffered. Paths in the archive are relative to
+// root. Close the reader to stop generating the archive before it's been fully
+// read.
+func NewTarReader(fsys fs.FS, root string) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeTar(pw, fsys, root))
+	}()
+	return pr
+}
+
+func writeTar(w io.Writer, fsys fs.FS, root string) error {
+	tw := tar.NewWriter(w)
+	err := fs.WalkDir(fsys, root, func(fpath string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip the root directory itself
+		if fpath == root && de.IsDir() {
+			return nil
+		}
+		name := tarName(root, fpath)
+		file, err := fsys.Open(fpath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		fi, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		// Override to use the full path name
+		header.Name = name
+		if de.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		// Don't try reading from directories or symlinks
+		if de.IsDir() || fi.Mode()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		if _, err := io.Copy(tw, file); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func tarName(root, fpath string) string {
+	if root == "." {
+		return fpath
+	} else if root == fpath {
+		// Root is a file
+		return path.Base(fpath)
+	}
+	return strings.TrimPrefix(fpath, root+"/")
+}
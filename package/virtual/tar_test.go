@@ -0,0 +1,104 @@
+package virtual_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/livebud/bud/internal/is"
+	"github.com/livebud/bud/package/virtual"
+)
+
+func TestTarReader(t *testing.T) {
+	is := is.New(t)
+	fsys := fstest.MapFS{
+		"bud/view/index.svelte":       &fstest.MapFile{Data: []byte(`<h1>index</h1>`), Mode: 0644},
+		"bud/view/about/index.svelte": &fstest.MapFile{Data: []byte(`<h1>about</h1>`), Mode: 0644},
+		"main.go":                     &fstest.MapFile{Data: []byte(`package main`), Mode: 0644},
+	}
+	tr := tar.NewReader(virtual.NewTarReader(fsys, "bud/view"))
+	files := map[string]string{}
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			is.NoErr(err)
+		}
+		names = append(names, header.Name)
+		data, err := io.ReadAll(tr)
+		is.NoErr(err)
+		files[header.Name] = string(data)
+	}
+	is.Equal(names, []string{"about/", "about/index.svelte", "index.svelte"})
+	is.Equal(files["about/index.svelte"], `<h1>about</h1>`)
+	is.Equal(files["index.svelte"], `<h1>index</h1>`)
+}
+
+func TestTarReaderNotExist(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{}
+	_, err := io.ReadAll(virtual.NewTarReader(fsys, "bud"))
+	is.True(errors.Is(err, fs.ErrNotExist))
+}
+
+// closeNotifyFS signals when an opened file is closed
+type closeNotifyFS struct {
+	fs.FS
+	closed chan string
+}
+
+func (c *closeNotifyFS) Open(name string) (fs.File, error) {
+	file, err := c.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := file.(fs.ReadDirFile); ok {
+		return file, nil
+	}
+	return &closeNotifyFile{file, name, c.closed}, nil
+}
+
+type closeNotifyFile struct {
+	fs.File
+	name   string
+	closed chan string
+}
+
+func (c *closeNotifyFile) Close() error {
+	c.closed <- c.name
+	return c.File.Close()
+}
+
+func TestTarReaderCloseEarly(t *testing.T) {
+	is := is.New(t)
+	fsys := &closeNotifyFS{
+		FS: fstest.MapFS{
+			"bud/a.txt": &fstest.MapFile{Data: bytes.Repeat([]byte("a"), 1<<20), Mode: 0644},
+			"bud/b.txt": &fstest.MapFile{Data: []byte("b"), Mode: 0644},
+		},
+		closed: make(chan string, 10),
+	}
+	reader := virtual.NewTarReader(fsys, "bud")
+	// Stop reading partway through the first file
+	buf := make([]byte, 1024)
+	_, err := io.ReadFull(reader, buf)
+	is.NoErr(err)
+	is.NoErr(reader.Close())
+	// The writer stops rather than blocking on the rest of the archive
+	select {
+	case name := <-fsys.closed:
+		is.Equal(name, "bud/a.txt")
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the tar writer to stop after closing the reader")
+	}
+	_, err = reader.Read(buf)
+	is.True(errors.Is(err, io.ErrClosedPipe))
+}
@@ -0,0 +1,114 @@
+package virtual
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// ErrTransactionDone is returned when using a transaction that has already
+// been committed or discarded
+var ErrTransactionDone = errors.New("virtual: transaction has already been committed or discarded")
+
+// NewTransaction buffers writes to fsys until they're committed
+func NewTransaction(fsys FS) *Transaction {
+	return &Transaction{fsys: fsys}
+}
+
+// Transaction writes a batch of files all at once
+type Transaction struct {
+	fsys   FS
+	writes []*File
+	done   bool
+}
+
+// WriteFile buffers a write until the transaction is committed
+func (t *Transaction) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if t.done {
+		return ErrTransactionDone
+	}
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	t.writes = append(t.writes, &File{Path: name, Data: data, Mode: perm})
+	return nil
+}
+
+// Commit writes all the buffered files. If any of the writes fail, the files
+// that were already written are restored to their previous state.
+func (t *Transaction) Commit() error {
+	if t.done {
+		return ErrTransactionDone
+	}
+	t.done = true
+	var undos []func() error
+	for _, file := range t.writes {
+		undo, err := t.write(file)
+		// Keep the undos of a partial write, like the directories it created
+		undos = append(undos, undo...)
+		if err != nil {
+			// Roll back in reverse order
+			for i := len(undos) - 1; i >= 0; i-- {
+				if rerr := undos[i](); rerr != nil {
+					return fmt.Errorf("virtual: unable to roll back transaction. %s. %w", rerr, err)
+				}
+			}
+			return fmt.Errorf("virtual: unable to commit transaction. %w", err)
+		}
+	}
+	t.writes = nil
+	return nil
+}
+
+// write the file, returning functions to undo the write
+func (t *Transaction) write(file *File) (undos []func() error, err error) {
+	// Find the directories that MkdirAll will create, so they can be removed
+	var missing []string
+	for dir := path.Dir(file.Path); dir != "."; dir = path.Dir(dir) {
+		if _, err := fs.Stat(t.fsys, dir); err == nil {
+			break
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		missing = append(missing, dir)
+	}
+	if len(missing) > 0 {
+		if err := t.fsys.MkdirAll(missing[0], 0755); err != nil {
+			return nil, err
+		}
+		// Undos run in reverse, so the deepest directory is removed first
+		for i := len(missing) - 1; i >= 0; i-- {
+			dir := missing[i]
+			undos = append(undos, func() error { return t.fsys.RemoveAll(dir) })
+		}
+	}
+	// Remember the previous file to restore it
+	prev, err := fs.ReadFile(t.fsys, file.Path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return undos, err
+	}
+	existed := err == nil
+	var mode fs.FileMode
+	if existed {
+		if stat, err := fs.Stat(t.fsys, file.Path); err == nil {
+			mode = stat.Mode()
+		}
+	}
+	if err := t.fsys.WriteFile(file.Path, file.Data, file.Mode); err != nil {
+		return undos, err
+	}
+	undos = append(undos, func() error {
+		if existed {
+			return t.fsys.WriteFile(file.Path, prev, mode)
+		}
+		return t.fsys.RemoveAll(file.Path)
+	})
+	return undos, nil
+}
+
+// Discard abandons the transaction without writing anything
+func (t *Transaction) Discard() {
+	t.done = true
+	t.writes = nil
+}
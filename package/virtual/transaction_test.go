@@ -0,0 +1,98 @@
+package virtual_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/livebud/bud/internal/is"
+	"github.com/livebud/bud/package/budfs/memfs"
+	"github.com/livebud/bud/package/virtual"
+)
+
+func TestTransaction(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	fsys := virtual.OS(dir)
+	tx := virtual.NewTransaction(fsys)
+	is.NoErr(tx.WriteFile("bud/a.txt", []byte("a"), 0644))
+	is.NoErr(tx.WriteFile("bud/b/b.txt", []byte("b"), 0644))
+	// Nothing is written until commit
+	_, err := os.Stat(filepath.Join(dir, "bud"))
+	is.True(errors.Is(err, fs.ErrNotExist))
+	is.NoErr(tx.Commit())
+	data, err := fs.ReadFile(fsys, "bud/a.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "a")
+	data, err = fs.ReadFile(fsys, "bud/b/b.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "b")
+	// Transactions can't be reused
+	is.True(errors.Is(tx.Commit(), virtual.ErrTransactionDone))
+	is.True(errors.Is(tx.WriteFile("c.txt", nil, 0644), virtual.ErrTransactionDone))
+}
+
+func TestTransactionDiscard(t *testing.T) {
+	is := is.New(t)
+	fsys := virtual.Map{}
+	tx := virtual.NewTransaction(fsys)
+	is.NoErr(tx.WriteFile("a.txt", []byte("a"), 0644))
+	tx.Discard()
+	is.Equal(len(fsys), 0)
+	is.True(errors.Is(tx.Commit(), virtual.ErrTransactionDone))
+}
+
+// failFS fails writing a specific file
+type failFS struct {
+	virtual.FS
+	fail string
+}
+
+func (f failFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if name == f.fail {
+		return fs.ErrPermission
+	}
+	return f.FS.WriteFile(name, data, perm)
+}
+
+func TestTransactionRollback(t *testing.T) {
+	is := is.New(t)
+	files := virtual.Map{
+		"b.txt": &virtual.File{Data: []byte("old b"), Mode: 0644},
+	}
+	fsys := failFS{files, "c.txt"}
+	tx := virtual.NewTransaction(fsys)
+	is.NoErr(tx.WriteFile("a.txt", []byte("a"), 0644))
+	is.NoErr(tx.WriteFile("b.txt", []byte("new b"), 0644))
+	is.NoErr(tx.WriteFile("c.txt", []byte("c"), 0644))
+	err := tx.Commit()
+	is.True(errors.Is(err, fs.ErrPermission))
+	// New files are removed and existing files are restored
+	is.Equal(len(files), 1)
+	data, err := fs.ReadFile(fsys, "b.txt")
+	is.NoErr(err)
+	is.Equal(string(data), "old b")
+}
+
+func TestTransactionRollbackDirs(t *testing.T) {
+	is := is.New(t)
+	mem := memfs.New()
+	is.NoErr(mem.WriteFile("a/keep.txt", []byte("keep"), 0644))
+	fsys := failFS{mem, "a/b/c/x.txt"}
+	tx := virtual.NewTransaction(fsys)
+	is.NoErr(tx.WriteFile("d/e/y.txt", []byte("y"), 0644))
+	is.NoErr(tx.WriteFile("a/b/c/x.txt", []byte("x"), 0644))
+	err := tx.Commit()
+	is.True(errors.Is(err, fs.ErrPermission))
+	// Every directory the commit created is removed
+	des, err := fs.ReadDir(mem, ".")
+	is.NoErr(err)
+	is.Equal(len(des), 1)
+	is.Equal(des[0].Name(), "a")
+	des, err = fs.ReadDir(mem, "a")
+	is.NoErr(err)
+	is.Equal(len(des), 1)
+	is.Equal(des[0].Name(), "keep.txt")
+}
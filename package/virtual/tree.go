@@ -82,14 +82,14 @@ func (fsys Tree) Open(path string) (fs.File, error) {
 
 // Mkdir create a directory.
 func (t Tree) MkdirAll(path string, perm fs.FileMode) error {
-	t[path] = &File{path, nil, perm | fs.ModeDir, time.Time{}}
+	t[path] = &File{Path: path, Mode: perm | fs.ModeDir}
 	return nil
 }
 
 // WriteFile writes a file
 // TODO: WriteFile should fail if path.Dir(name) doesn't exist
 func (t Tree) WriteFile(path string, data []byte, perm fs.FileMode) error {
-	t[path] = &File{path, data, perm, time.Time{}}
+	t[path] = &File{Path: path, Data: data, Mode: perm}
 	return nil
 }
 
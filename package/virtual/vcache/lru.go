@@ -0,0 +1,205 @@
+package vcache
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/livebud/bud/package/virtual"
+)
+
+// LRU creates a cache that evicts the least recently used entries when the
+// total size of the cached file data exceeds maxBytes. A maxBytes of zero or
+// less means the cache is unbounded.
+func LRU(maxBytes int64) *LRUCache {
+	return &LRUCache{
+		max:   maxBytes,
+		ll:    list.New(),
+		items: map[string]*list.Element{},
+		now:   time.Now,
+	}
+}
+
+// LRUCache is a memory-bounded cache. LRUCache is safe for concurrent use.
+type LRUCache struct {
+	mu    sync.Mutex
+	max   int64
+	size  int64
+	ll    *list.List
+	items map[string]*list.Element
+	now   func() time.Time
+
+	// Usage for monitoring
+	hits   int64
+	misses int64
+}
+
+var _ Cache = (*LRUCache)(nil)
+
+type lruItem struct {
+	path   string
+	entry  virtual.Entry
+	size   int64
+	hits   int64
+	access time.Time
+}
+
+func entrySize(entry virtual.Entry) int64 {
+	if file, ok := entry.(*virtual.File); ok {
+		return int64(len(file.Data))
+	}
+	return 0
+}
+
+func (c *LRUCache) Has(path string) (ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok = c.items[path]
+	return ok
+}
+
+func (c *LRUCache) Get(path string) (entry virtual.Entry, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[path]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.ll.MoveToFront(el)
+	item := el.Value.(*lruItem)
+	item.hits++
+	item.access = c.now()
+	return item.entry, true
+}
+
+func (c *LRUCache) Set(path string, entry virtual.Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	size := entrySize(entry)
+	if el, ok := c.items[path]; ok {
+		item := el.Value.(*lruItem)
+		c.size += size - item.size
+		item.entry = entry
+		item.size = size
+		item.access = c.now()
+		c.ll.MoveToFront(el)
+	} else {
+		c.items[path] = c.ll.PushFront(&lruItem{path: path, entry: entry, size: size, access: c.now()})
+		c.size += size
+	}
+	c.evict()
+}
+
+func (c *LRUCache) Delete(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[path]; ok {
+		c.remove(el)
+	}
+}
+
+func (c *LRUCache) Range(fn func(path string, entry virtual.Entry) bool) {
+	c.mu.Lock()
+	items := make([]*lruItem, 0, c.ll.Len())
+	for el := c.ll.Front(); el != nil; el = el.Next() {
+		items = append(items, el.Value.(*lruItem))
+	}
+	c.mu.Unlock()
+	// Call fn outside the lock so fn can modify the cache
+	for _, item := range items {
+		if !fn(item.path, item.entry) {
+			return
+		}
+	}
+}
+
+func (c *LRUCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	c.items = map[string]*list.Element{}
+	c.size = 0
+	c.hits = 0
+	c.misses = 0
+}
+
+// SetClock replaces the clock used to stamp when entries were last accessed.
+// The clock defaults to time.Now.
+func (c *LRUCache) SetClock(clock func() time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = clock
+}
+
+// SetMaxBytes changes the memory budget, evicting entries if needed
+func (c *LRUCache) SetMaxBytes(maxBytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.max = maxBytes
+	c.evict()
+}
+
+// Size returns the total size of the cached file data in bytes
+func (c *LRUCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Stat is the usage of a cached entry
+type Stat struct {
+	Path       string
+	Size       int64
+	Hits       int64
+	LastAccess time.Time // Last time the entry was set or read
+}
+
+// Stats returns the usage of each cached entry, sorted by path
+func (c *LRUCache) Stats() []Stat {
+	c.mu.Lock()
+	stats := make([]Stat, 0, len(c.items))
+	for path, el := range c.items {
+		item := el.Value.(*lruItem)
+		stats = append(stats, Stat{path, item.size, item.hits, item.access})
+	}
+	c.mu.Unlock()
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Path < stats[j].Path
+	})
+	return stats
+}
+
+// HitRate returns the fraction of reads that were found in the cache, or zero
+// if the cache hasn't been read
+func (c *LRUCache) HitRate() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hits+c.misses == 0 {
+		return 0
+	}
+	return float64(c.hits) / float64(c.hits+c.misses)
+}
+
+// evict the least recently used entries until we're within budget
+func (c *LRUCache) evict() {
+	if c.max <= 0 {
+		return
+	}
+	for c.size > c.max {
+		el := c.ll.Back()
+		if el == nil {
+			return
+		}
+		c.remove(el)
+	}
+}
+
+func (c *LRUCache) remove(el *list.Element) {
+	item := el.Value.(*lruItem)
+	c.ll.Remove(el)
+	delete(c.items, item.path)
+	c.size -= item.size
+}
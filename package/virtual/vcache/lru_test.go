@@ -0,0 +1,95 @@
+package vcache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livebud/bud/internal/is"
+	"github.com/livebud/bud/package/virtual"
+	"github.com/livebud/bud/package/virtual/vcache"
+)
+
+func TestLRU(t *testing.T) {
+	is := is.New(t)
+	cache := vcache.LRU(10)
+	cache.Set("a.txt", &virtual.File{Data: []byte("aaaa")})
+	cache.Set("b.txt", &virtual.File{Data: []byte("bbbb")})
+	cache.Set("dir", &virtual.Dir{})
+	is.Equal(cache.Size(), int64(8))
+	// Touch a.txt so b.txt is the least recently used file
+	_, ok := cache.Get("a.txt")
+	is.True(ok)
+	cache.Set("c.txt", &virtual.File{Data: []byte("cccc")})
+	is.Equal(cache.Size(), int64(8))
+	is.True(cache.Has("a.txt"))
+	is.True(cache.Has("c.txt"))
+	is.True(!cache.Has("b.txt"))
+	// Overwrite an entry
+	cache.Set("c.txt", &virtual.File{Data: []byte("c")})
+	is.Equal(cache.Size(), int64(5))
+	// Shrink the budget
+	cache.SetMaxBytes(1)
+	is.Equal(cache.Size(), int64(1))
+	is.True(cache.Has("c.txt"))
+	is.True(!cache.Has("a.txt"))
+	// Delete and clear
+	cache.Delete("c.txt")
+	is.Equal(cache.Size(), int64(0))
+	cache.Set("d.txt", &virtual.File{Data: []byte("d")})
+	cache.Clear()
+	is.True(!cache.Has("d.txt"))
+	is.Equal(cache.Size(), int64(0))
+}
+
+func TestLRUUnbounded(t *testing.T) {
+	is := is.New(t)
+	cache := vcache.LRU(0)
+	for _, path := range []string{"a.txt", "b.txt", "c.txt"} {
+		cache.Set(path, &virtual.File{Data: []byte("data")})
+	}
+	is.Equal(cache.Size(), int64(12))
+	count := 0
+	cache.Range(func(path string, entry virtual.Entry) bool {
+		count++
+		return true
+	})
+	is.Equal(count, 3)
+}
+
+func TestLRUStats(t *testing.T) {
+	is := is.New(t)
+	cache := vcache.LRU(0)
+	is.Equal(cache.HitRate(), float64(0))
+	cache.Set("b.txt", &virtual.File{Data: []byte("bb")})
+	cache.Set("a.txt", &virtual.File{Data: []byte("a")})
+	_, ok := cache.Get("a.txt")
+	is.True(ok)
+	_, ok = cache.Get("a.txt")
+	is.True(ok)
+	_, ok = cache.Get("b.txt")
+	is.True(ok)
+	_, ok = cache.Get("c.txt")
+	is.True(!ok)
+	is.Equal(cache.HitRate(), 0.75)
+	stats := cache.Stats()
+	is.Equal(len(stats), 2)
+	is.Equal(stats[0].Path, "a.txt")
+	is.Equal(stats[0].Size, int64(1))
+	is.Equal(stats[0].Hits, int64(2))
+	is.True(!stats[0].LastAccess.IsZero())
+	is.Equal(stats[1].Path, "b.txt")
+	is.Equal(stats[1].Size, int64(2))
+	is.Equal(stats[1].Hits, int64(1))
+	cache.Clear()
+	is.Equal(cache.HitRate(), float64(0))
+	is.Equal(len(cache.Stats()), 0)
+	// Accesses are stamped with the cache's clock
+	now := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+	cache.SetClock(func() time.Time { return now })
+	cache.Set("a.txt", &virtual.File{Data: []byte("a")})
+	is.Equal(cache.Stats()[0].LastAccess, now)
+	now = now.Add(time.Minute)
+	_, ok = cache.Get("a.txt")
+	is.True(ok)
+	is.Equal(cache.Stats()[0].LastAccess, now)
+}